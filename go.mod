@@ -2,10 +2,6 @@ module exporter-dev/http-server
 
 go 1.19
 
-require rsc.io/quote v1.5.2
+require golang.org/x/net v0.35.0
 
-require (
-	golang.org/x/exp v0.0.0-20230105202349-8879d0199aa3
-	golang.org/x/text v0.0.0-20170915032832-14c0d48ead0c // indirect
-	rsc.io/sampler v1.3.0 // indirect
-)
+require golang.org/x/text v0.22.0 // indirect
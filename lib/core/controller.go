@@ -1,9 +1,13 @@
 package server
 
 import (
+	"context"
+	"errors"
 	"io"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync"
 )
 
 type ControllerHeader struct {
@@ -17,6 +21,12 @@ type ControllerInterface interface {
 	Header() *ControllerHeader
 }
 
+// Controller which could stream response by chunks, see Controller.Stream
+type StreamingController interface {
+	ControllerInterface
+	Stream(content string) error
+}
+
 type Response struct {
 	Content *[]byte
 	Status  *int
@@ -36,6 +46,27 @@ type Request struct {
 	Context    *Context
 	Route      *Route
 	Original   *http.Request
+	routing    *Routing
+	matchPath  string
+}
+
+// Get context of original request, canceled when client is gone or request deadline is exceeded
+func (r *Request) Ctx() context.Context {
+	if r.Original == nil {
+		return context.Background()
+	}
+
+	return r.Original.Context()
+}
+
+// Get methods allowed for host and path of request by routing, empty when request
+// is not handled by Server, see Routing.AllowedHost
+func (r *Request) allowedMethods() []string {
+	if r.routing == nil || r.Original == nil {
+		return []string{}
+	}
+
+	return r.routing.AllowedHost(r.Original.Host, r.matchPath)
 }
 
 // Read request body
@@ -77,14 +108,23 @@ func NewRequest(request *http.Request, params Params, route *Route) (*Request, e
 	}, err
 }
 
+// Returned by Controller when response was already finished, for example by timeout
+var ErrControllerClosed = errors.New("controller is closed")
+
 type Controller struct {
-	request  *http.Request
-	response http.ResponseWriter
-	headers  map[string][]string
-	status   int
-	content  []byte
-	Header   *ControllerHeader
-	Response *Response
+	request    *http.Request
+	response   http.ResponseWriter
+	headers    map[string][]string
+	status     int
+	content    []byte
+	written    int
+	headerSent bool
+	deferred   bool
+	pending    bool
+	closed     bool
+	mutex      sync.Mutex
+	Header     *ControllerHeader
+	Response   *Response
 }
 
 // Creates new Controller
@@ -109,37 +149,161 @@ func NewController(request *http.Request, response http.ResponseWriter) *Control
 	return controller
 }
 
-// Send string content, all header and status to client
+// Send string content, all header and status to client. For HEAD requests only Content-Length
+// of content is sent. When executed by Server response is written after middlewares are finished
 func (controller *Controller) Send(content string) error {
+	controller.mutex.Lock()
+	defer controller.mutex.Unlock()
+
+	if controller.closed {
+		return ErrControllerClosed
+	}
+
 	if len(content) > 0 {
 		controller.content = append(controller.content, []byte(content)...)
 	}
 
-	for key, values := range controller.headers {
-		for _, value := range values {
-			controller.response.Header().Add(key, value)
+	if controller.deferred {
+		controller.pending = true
+
+		return nil
+	}
+
+	return controller.flush()
+}
+
+// Write content sent by Controller.Send, for HEAD requests only Content-Length of content is sent
+func (controller *Controller) flush() error {
+	controller.pending = false
+
+	if controller.request.Method == HEAD && !controller.headerSent {
+		if controller.response.Header().Get(HEADER_KEY_CONTENT_LENGTH) == "" {
+			controller.response.Header().Set(HEADER_KEY_CONTENT_LENGTH, strconv.Itoa(len(controller.content)))
 		}
 	}
 
-	controller.response.WriteHeader(controller.status)
+	return controller.write()
+}
+
+// Send string content to client immediately and flush it, headers and status are sent with the first chunk.
+// Used to stream response by chunks, for HEAD requests content is not sent
+func (controller *Controller) Stream(content string) error {
+	controller.mutex.Lock()
+	defer controller.mutex.Unlock()
+
+	if controller.closed {
+		return ErrControllerClosed
+	}
+
+	if len(content) > 0 {
+		controller.content = append(controller.content, []byte(content)...)
+	}
+
+	if err := controller.write(); err != nil {
+		return err
+	}
+
+	if flusher, ok := controller.response.(http.Flusher); ok {
+		flusher.Flush()
+	}
+
+	return nil
+}
+
+// Write content sent by Controller.Send when it was deferred
+func (controller *Controller) finish() error {
+	controller.mutex.Lock()
+	defer controller.mutex.Unlock()
+
+	if controller.closed || !controller.pending {
+		return nil
+	}
+
+	return controller.flush()
+}
+
+// Close controller and write status with content instead of response, returns false when
+// controller is already closed. Content sent by Controller.Send is discarded, headers and
+// status are not written when response was already streamed
+func (controller *Controller) abort(status int, content string) bool {
+	controller.mutex.Lock()
+	defer controller.mutex.Unlock()
+
+	if controller.closed {
+		return false
+	}
+
+	controller.closed = true
+	controller.pending = false
+
+	if !controller.headerSent {
+		controller.response.WriteHeader(status)
+		controller.headerSent = true
+	}
+
+	controller.response.Write([]byte(content))
+
+	return true
+}
+
+// Close controller without writing response, later sends return ErrControllerClosed
+func (controller *Controller) close() {
+	controller.mutex.Lock()
+	defer controller.mutex.Unlock()
+
+	controller.closed = true
+	controller.pending = false
+}
 
-	_, err := controller.response.Write(controller.content)
+// Write headers and status once and content which was not written yet
+func (controller *Controller) write() error {
+	if !controller.headerSent {
+		controller.writeHeaders()
+		controller.response.WriteHeader(controller.status)
+		controller.headerSent = true
+	}
+
+	if controller.request.Method == HEAD {
+		return nil
+	}
+
+	_, err := controller.response.Write(controller.content[controller.written:])
+
+	controller.written = len(controller.content)
 
 	return err
 }
 
+// Copy headers set by controller to response
+func (controller *Controller) writeHeaders() {
+	for key, values := range controller.headers {
+		for _, value := range values {
+			controller.response.Header().Add(key, value)
+		}
+	}
+}
+
 // Append bytes of response buffer, not sending to client
 func (controller *Controller) Append(bytes []byte) {
+	controller.mutex.Lock()
+	defer controller.mutex.Unlock()
+
 	controller.content = append(controller.content, bytes...)
 }
 
 // Set status of response, not sending to client
 func (controller *Controller) Status(status int) {
+	controller.mutex.Lock()
+	defer controller.mutex.Unlock()
+
 	controller.status = status
 }
 
 // Get header by key, if ignoreCase is true - will ignore case when matching headers
 func (header *ControllerHeader) Get(key string, ignoreCase ...bool) []string {
+	header.controller.mutex.Lock()
+	defer header.controller.mutex.Unlock()
+
 	var found []string = []string{}
 
 	if ignoreCase[0] {
@@ -161,11 +325,17 @@ func (header *ControllerHeader) Get(key string, ignoreCase ...bool) []string {
 
 // Clear all set headers
 func (header *ControllerHeader) Clear() {
+	header.controller.mutex.Lock()
+	defer header.controller.mutex.Unlock()
+
 	header.controller.headers = map[string][]string{}
 }
 
 // Remove headers by key
 func (header *ControllerHeader) Remove(key string, ignoreCase bool) {
+	header.controller.mutex.Lock()
+	defer header.controller.mutex.Unlock()
+
 	if ignoreCase {
 		key = strings.ToLower(key)
 	}
@@ -175,6 +345,9 @@ func (header *ControllerHeader) Remove(key string, ignoreCase bool) {
 
 // Add header by key and value
 func (header *ControllerHeader) Add(key string, value string) {
+	header.controller.mutex.Lock()
+	defer header.controller.mutex.Unlock()
+
 	if header.controller.headers[key] != nil {
 		header.controller.headers[key] = append(header.controller.headers[key], value)
 	} else {
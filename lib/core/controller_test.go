@@ -46,3 +46,42 @@ func TestController(t *testing.T) {
 		}
 	})
 }
+
+type RecordingResponseWriter struct {
+	MockResponseWriter
+	statuses []int
+	written  []byte
+}
+
+func (response *RecordingResponseWriter) WriteHeader(status int) {
+	response.statuses = append(response.statuses, status)
+}
+
+func (response *RecordingResponseWriter) Write(bytes []byte) (int, error) {
+	response.written = append(response.written, bytes...)
+	return len(bytes), nil
+}
+
+func TestControllerStream(t *testing.T) {
+	t.Run("Should write status once and every chunk once", func(t *testing.T) {
+		mockResponse := &RecordingResponseWriter{}
+
+		controller := &Controller{
+			response: mockResponse,
+			request:  new(http.Request),
+			status:   201,
+		}
+
+		controller.Stream("A")
+		controller.Stream("B")
+		controller.Send("C")
+
+		if len(mockResponse.statuses) != 1 || mockResponse.statuses[0] != 201 {
+			t.Fatalf("%v expected to be [201]", mockResponse.statuses)
+		}
+
+		if string(mockResponse.written) != "ABC" {
+			t.Fatalf("%v expected to be ABC", string(mockResponse.written))
+		}
+	})
+}
@@ -0,0 +1,152 @@
+package server
+
+import (
+	"exporter-dev/http-server/lib/utils"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	HEADER_KEY_ORIGIN                           = "Origin"
+	HEADER_KEY_VARY                             = "Vary"
+	HEADER_KEY_ACCESS_CONTROL_ALLOW_ORIGIN      = "Access-Control-Allow-Origin"
+	HEADER_KEY_ACCESS_CONTROL_ALLOW_METHODS     = "Access-Control-Allow-Methods"
+	HEADER_KEY_ACCESS_CONTROL_ALLOW_HEADERS     = "Access-Control-Allow-Headers"
+	HEADER_KEY_ACCESS_CONTROL_ALLOW_CREDENTIALS = "Access-Control-Allow-Credentials"
+	HEADER_KEY_ACCESS_CONTROL_EXPOSE_HEADERS    = "Access-Control-Expose-Headers"
+	HEADER_KEY_ACCESS_CONTROL_MAX_AGE           = "Access-Control-Max-Age"
+	HEADER_KEY_ACCESS_CONTROL_REQUEST_METHOD    = "Access-Control-Request-Method"
+	HEADER_KEY_ACCESS_CONTROL_REQUEST_HEADERS   = "Access-Control-Request-Headers"
+)
+
+// Options of CORS middleware
+type CORSOptions struct {
+	// Allowed origins, exact like "https://example.com", with wildcard like "https://*.example.com"
+	// matching one or more host labels, or "*" for any origin
+	AllowedOrigins []string
+	// Methods allowed for preflight requests, methods of routes matching request path when empty
+	AllowedMethods []string
+	// Headers allowed for preflight requests, requested headers are allowed when empty
+	AllowedHeaders []string
+	// Response headers exposed to client
+	ExposedHeaders []string
+	// Allow cookies and authorization, origin is sent instead of "*" for any origin
+	AllowCredentials bool
+	// How long preflight response could be cached, not sent when zero
+	MaxAge time.Duration
+}
+
+// Origins allowed by CORSOptions
+type originMatcher struct {
+	any      bool
+	exact    map[string]bool
+	patterns []*regexp.Regexp
+}
+
+func compileOrigins(origins []string) *originMatcher {
+	matcher := &originMatcher{
+		exact: map[string]bool{},
+	}
+
+	for _, origin := range origins {
+		origin = strings.ToLower(origin)
+
+		switch {
+		case origin == "*":
+			matcher.any = true
+		case strings.Contains(origin, "*"):
+			quoted := strings.ReplaceAll(regexp.QuoteMeta(origin), `\*`, `[a-z0-9-]+(\.[a-z0-9-]+)*`)
+
+			matcher.patterns = append(matcher.patterns, regexp.MustCompile("^"+quoted+"$"))
+		default:
+			matcher.exact[origin] = true
+		}
+	}
+
+	return matcher
+}
+
+func (m *originMatcher) Match(origin string) bool {
+	origin = strings.ToLower(origin)
+
+	if m.any || m.exact[origin] {
+		return true
+	}
+
+	return utils.Some(m.patterns, func(pattern *regexp.Regexp, index int) bool {
+		return pattern.MatchString(origin)
+	})
+}
+
+// Creates new CORS Middleware adding CORS headers for requests from allowed origins.
+// Preflight OPTIONS requests to paths with routes are answered by middleware with 204
+// without executing route handler
+func NewCORSMiddleware(options CORSOptions) *Middleware {
+	origins := compileOrigins(options.AllowedOrigins)
+
+	return NewMiddleware(func(request *Request, controller *Controller) (skip bool, err error) {
+		headers := http.Header(request.Headers)
+		origin := headers.Get(HEADER_KEY_ORIGIN)
+
+		preflight := request.Method == OPTIONS && headers.Get(HEADER_KEY_ACCESS_CONTROL_REQUEST_METHOD) != ""
+
+		controller.Header.Add(HEADER_KEY_VARY, HEADER_KEY_ORIGIN)
+
+		if preflight {
+			controller.Header.Add(HEADER_KEY_VARY, HEADER_KEY_ACCESS_CONTROL_REQUEST_METHOD)
+			controller.Header.Add(HEADER_KEY_VARY, HEADER_KEY_ACCESS_CONTROL_REQUEST_HEADERS)
+		}
+
+		if origin == "" || !origins.Match(origin) {
+			return false, nil
+		}
+
+		if origins.any && !options.AllowCredentials {
+			controller.Header.Add(HEADER_KEY_ACCESS_CONTROL_ALLOW_ORIGIN, "*")
+		} else {
+			controller.Header.Add(HEADER_KEY_ACCESS_CONTROL_ALLOW_ORIGIN, origin)
+		}
+
+		if options.AllowCredentials {
+			controller.Header.Add(HEADER_KEY_ACCESS_CONTROL_ALLOW_CREDENTIALS, "true")
+		}
+
+		if !preflight {
+			if len(options.ExposedHeaders) > 0 {
+				controller.Header.Add(HEADER_KEY_ACCESS_CONTROL_EXPOSE_HEADERS, strings.Join(options.ExposedHeaders, ", "))
+			}
+
+			return false, nil
+		}
+
+		methods := options.AllowedMethods
+
+		if len(methods) == 0 {
+			methods = request.allowedMethods()
+		}
+
+		// Path without routes is left to Server, which responds with 404
+		if len(methods) == 0 {
+			return false, nil
+		}
+
+		controller.Header.Add(HEADER_KEY_ACCESS_CONTROL_ALLOW_METHODS, strings.Join(methods, ", "))
+
+		if len(options.AllowedHeaders) > 0 {
+			controller.Header.Add(HEADER_KEY_ACCESS_CONTROL_ALLOW_HEADERS, strings.Join(options.AllowedHeaders, ", "))
+		} else if requested := headers.Get(HEADER_KEY_ACCESS_CONTROL_REQUEST_HEADERS); requested != "" {
+			controller.Header.Add(HEADER_KEY_ACCESS_CONTROL_ALLOW_HEADERS, requested)
+		}
+
+		if options.MaxAge > 0 {
+			controller.Header.Add(HEADER_KEY_ACCESS_CONTROL_MAX_AGE, strconv.Itoa(int(options.MaxAge.Seconds())))
+		}
+
+		controller.Status(http.StatusNoContent)
+
+		return true, controller.Send("")
+	})
+}
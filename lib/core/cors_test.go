@@ -0,0 +1,174 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCORSMiddleware(t *testing.T) {
+	var handled bool
+
+	instance := NewServer()
+
+	instance.Use(*NewCORSMiddleware(CORSOptions{
+		AllowedOrigins:   []string{"https://example.com", "https://*.example.org"},
+		ExposedHeaders:   []string{"X-Total"},
+		AllowCredentials: true,
+		MaxAge:           time.Hour,
+	}))
+
+	handler := func(request *Request, controller *Controller) error {
+		handled = true
+		return controller.Send("users")
+	}
+
+	instance.Get(*NewRoute("/users", handler))
+	instance.Post(*NewRoute("/users", handler))
+
+	if err := instance.Prepare(); err != nil {
+		t.Fatalf("Got error while preparing handler: %v", err)
+	}
+
+	serve := func(method string, target string, headers map[string]string) *httptest.ResponseRecorder {
+		handled = false
+
+		request := httptest.NewRequest(method, target, nil)
+
+		for key, value := range headers {
+			request.Header.Set(key, value)
+		}
+
+		recorder := httptest.NewRecorder()
+
+		instance.Handler().ServeHTTP(recorder, request)
+
+		return recorder
+	}
+
+	t.Run("Should answer preflight with methods of routes", func(t *testing.T) {
+		recorder := serve(OPTIONS, "/users", map[string]string{
+			HEADER_KEY_ORIGIN:                         "https://api.eu.example.org",
+			HEADER_KEY_ACCESS_CONTROL_REQUEST_METHOD:  POST,
+			HEADER_KEY_ACCESS_CONTROL_REQUEST_HEADERS: "Authorization",
+		})
+
+		if recorder.Code != http.StatusNoContent || handled {
+			t.Fatalf("%v expected to be %v without handler", recorder.Code, http.StatusNoContent)
+		}
+
+		expected := map[string]string{
+			HEADER_KEY_ACCESS_CONTROL_ALLOW_ORIGIN:      "https://api.eu.example.org",
+			HEADER_KEY_ACCESS_CONTROL_ALLOW_METHODS:     "GET, HEAD, POST, OPTIONS",
+			HEADER_KEY_ACCESS_CONTROL_ALLOW_HEADERS:     "Authorization",
+			HEADER_KEY_ACCESS_CONTROL_ALLOW_CREDENTIALS: "true",
+			HEADER_KEY_ACCESS_CONTROL_MAX_AGE:           "3600",
+		}
+
+		for key, value := range expected {
+			if header := recorder.Header().Get(key); header != value {
+				t.Fatalf("%v: %v expected to be %v", key, header, value)
+			}
+		}
+	})
+
+	t.Run("Should add headers to request from allowed origin", func(t *testing.T) {
+		recorder := serve(GET, "/users", map[string]string{HEADER_KEY_ORIGIN: "https://example.com"})
+
+		if recorder.Body.String() != "users" || !handled {
+			t.Fatalf("%v expected to be %v", recorder.Body.String(), "users")
+		}
+
+		if origin := recorder.Header().Get(HEADER_KEY_ACCESS_CONTROL_ALLOW_ORIGIN); origin != "https://example.com" {
+			t.Fatalf("%v expected to be %v", origin, "https://example.com")
+		}
+
+		if exposed := recorder.Header().Get(HEADER_KEY_ACCESS_CONTROL_EXPOSE_HEADERS); exposed != "X-Total" {
+			t.Fatalf("%v expected to be %v", exposed, "X-Total")
+		}
+	})
+
+	t.Run("Should not add headers to request from other origin", func(t *testing.T) {
+		for _, origin := range []string{"https://example.net", "https://example.org", "https://evil.com/.example.org"} {
+			recorder := serve(OPTIONS, "/users", map[string]string{
+				HEADER_KEY_ORIGIN:                        origin,
+				HEADER_KEY_ACCESS_CONTROL_REQUEST_METHOD: GET,
+			})
+
+			if header := recorder.Header().Get(HEADER_KEY_ACCESS_CONTROL_ALLOW_ORIGIN); header != "" {
+				t.Fatalf("%v expected to be empty for %v", header, origin)
+			}
+
+			if vary := recorder.Header().Values(HEADER_KEY_VARY); len(vary) == 0 || vary[0] != HEADER_KEY_ORIGIN {
+				t.Fatalf("%v expected to contain %v", vary, HEADER_KEY_ORIGIN)
+			}
+		}
+	})
+
+	t.Run("Should leave preflight to path without routes", func(t *testing.T) {
+		recorder := serve(OPTIONS, "/unknown", map[string]string{
+			HEADER_KEY_ORIGIN:                        "https://example.com",
+			HEADER_KEY_ACCESS_CONTROL_REQUEST_METHOD: GET,
+		})
+
+		if recorder.Code != http.StatusNotFound {
+			t.Fatalf("%v expected to be %v", recorder.Code, http.StatusNotFound)
+		}
+	})
+}
+
+func TestCORSMiddlewareGroup(t *testing.T) {
+	instance := NewServer()
+
+	handler := func(request *Request, controller *Controller) error {
+		return controller.Send("")
+	}
+
+	instance.Group("/g").Use(*NewCORSMiddleware(CORSOptions{
+		AllowedOrigins: []string{"https://example.com"},
+	})).Post(*NewRoute("/b", handler))
+
+	instance.Post(*NewRoute("/other", handler))
+
+	if err := instance.Prepare(); err != nil {
+		t.Fatalf("Got error while preparing handler: %v", err)
+	}
+
+	preflight := func(target string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(OPTIONS, target, nil)
+
+		request.Header.Set(HEADER_KEY_ORIGIN, "https://example.com")
+		request.Header.Set(HEADER_KEY_ACCESS_CONTROL_REQUEST_METHOD, POST)
+
+		recorder := httptest.NewRecorder()
+
+		instance.Handler().ServeHTTP(recorder, request)
+
+		return recorder
+	}
+
+	t.Run("Should answer preflight by CORS middleware of group", func(t *testing.T) {
+		recorder := preflight("/g/b")
+
+		if recorder.Code != http.StatusNoContent {
+			t.Fatalf("%v expected to be %v", recorder.Code, http.StatusNoContent)
+		}
+
+		if origin := recorder.Header().Get(HEADER_KEY_ACCESS_CONTROL_ALLOW_ORIGIN); origin != "https://example.com" {
+			t.Fatalf("%v expected to be %v", origin, "https://example.com")
+		}
+
+		if methods := recorder.Header().Get(HEADER_KEY_ACCESS_CONTROL_ALLOW_METHODS); methods != "POST, OPTIONS" {
+			t.Fatalf("%v expected to be %v", methods, "POST, OPTIONS")
+		}
+	})
+
+	t.Run("Should not add headers to preflight of route outside group", func(t *testing.T) {
+		recorder := preflight("/other")
+
+		if origin := recorder.Header().Get(HEADER_KEY_ACCESS_CONTROL_ALLOW_ORIGIN); origin != "" {
+			t.Fatalf("%v expected to be empty", origin)
+		}
+	})
+}
@@ -0,0 +1,119 @@
+package server
+
+// Group of routes sharing path prefix and middlewares, groups could be nested
+type Group struct {
+	Path        string
+	Host        string
+	parent      *Group
+	server      *Server
+	middlewares []Middleware
+}
+
+// Create nested group, path is added to path of current group
+func (g *Group) Group(path string) *Group {
+	return g.server.newGroup(path, g)
+}
+
+// Set host pattern for routes of the group and its nested groups, see Route.SetHost
+func (g *Group) SetHost(host string) *Group {
+	g.Host = host
+
+	return g
+}
+
+// Get host pattern of the group, inherited from the nearest parent group when not set
+func (g *Group) host() string {
+	if g == nil {
+		return ""
+	}
+
+	if g.Host != "" {
+		return g.Host
+	}
+
+	return g.parent.host()
+}
+
+// Register middlewares executed only for routes of the group and its nested groups,
+// middlewares registered after Server.Prepare are ignored
+func (g *Group) Use(middlewares ...Middleware) *Group {
+	g.middlewares = append(g.middlewares, middlewares...)
+
+	return g
+}
+
+// Get full path of the group including paths of parent groups
+func (g *Group) FullPath() string {
+	if g == nil {
+		return ""
+	}
+
+	return g.parent.FullPath() + g.Path
+}
+
+// Get groups from the outermost to current one
+func (g *Group) chain() []*Group {
+	if g == nil {
+		return nil
+	}
+
+	return append(g.parent.chain(), g)
+}
+
+// Register routes for method, route paths are prefixed with full path of the group
+func (g *Group) Handle(method string, routes ...Route) *Group {
+	g.server.Handle(method, g.prepare(routes)...)
+
+	return g
+}
+
+// Register routes for every method from ANY_METHODS
+func (g *Group) Any(routes ...Route) *Group {
+	g.server.Any(g.prepare(routes)...)
+
+	return g
+}
+
+func (g *Group) Get(routes ...Route) *Group {
+	return g.Handle(GET, routes...)
+}
+
+func (g *Group) Post(routes ...Route) *Group {
+	return g.Handle(POST, routes...)
+}
+
+func (g *Group) Put(routes ...Route) *Group {
+	return g.Handle(PUT, routes...)
+}
+
+func (g *Group) Patch(routes ...Route) *Group {
+	return g.Handle(PATCH, routes...)
+}
+
+func (g *Group) Delete(routes ...Route) *Group {
+	return g.Handle(DELETE, routes...)
+}
+
+func (g *Group) Head(routes ...Route) *Group {
+	return g.Handle(HEAD, routes...)
+}
+
+func (g *Group) Options(routes ...Route) *Group {
+	return g.Handle(OPTIONS, routes...)
+}
+
+func (g *Group) prepare(routes []Route) []Route {
+	path := g.FullPath()
+	host := g.host()
+
+	for index := range routes {
+		routes[index].Path = path + routes[index].Path
+		routes[index].Group = g
+
+		if routes[index].Host == "" {
+			routes[index].Host = host
+		}
+	}
+
+	return routes
+}
@@ -0,0 +1,118 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+)
+
+var captureRegexp = regexp.MustCompile(`\{[^}]*\}`)
+
+// Compiled host pattern, exact host like "example.com" or with {name} captures
+// like "{tenant}.example.com", each capture matches exactly one host label
+type hostMatcher struct {
+	pattern string
+	regexp  *regexp.Regexp
+	names   []string
+}
+
+// Compile host pattern, returns error when capture is not closed or has empty name
+func compileHost(pattern string) (*hostMatcher, error) {
+	matcher := &hostMatcher{
+		pattern: strings.ToLower(pattern),
+	}
+
+	if !strings.Contains(pattern, "{") {
+		return matcher, nil
+	}
+
+	hostRegexp := ""
+	rest := pattern
+
+	for {
+		start := strings.IndexByte(rest, '{')
+
+		if start < 0 {
+			hostRegexp += regexp.QuoteMeta(rest)
+			break
+		}
+
+		end := strings.IndexByte(rest[start:], '}')
+
+		if end < 0 {
+			return nil, fmt.Errorf("invalid host %q: capture is not closed", pattern)
+		}
+
+		name := rest[start+1 : start+end]
+
+		if name == "" {
+			return nil, fmt.Errorf("invalid host %q: capture should have name", pattern)
+		}
+
+		hostRegexp += regexp.QuoteMeta(rest[:start]) + `([^.]+)`
+		matcher.names = append(matcher.names, name)
+
+		rest = rest[start+end+1:]
+	}
+
+	r, err := regexp.Compile(`(?i)^` + hostRegexp + `$`)
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid host %q: %w", pattern, err)
+	}
+
+	matcher.regexp = r
+
+	return matcher, nil
+}
+
+// Check if matcher should be tried before other, exact hosts are more specific than wildcard ones
+// and wildcard hosts with less captures are more specific than others
+func (h *hostMatcher) moreSpecific(other *hostMatcher) bool {
+	if (h.regexp == nil) != (other.regexp == nil) {
+		return h.regexp == nil
+	}
+
+	return len(h.names) < len(other.names)
+}
+
+// Get host pattern with capture names removed, patterns with the same signature match the same hosts
+func hostSignature(pattern string) string {
+	return captureRegexp.ReplaceAllString(strings.ToLower(pattern), "{}")
+}
+
+// Match requested host without port, returns captured values
+func (h *hostMatcher) Match(host string) (Params, bool) {
+	host = stripHostPort(host)
+
+	if h.regexp == nil {
+		return nil, strings.EqualFold(h.pattern, host)
+	}
+
+	hostMatch := h.regexp.FindStringSubmatch(host)
+
+	if hostMatch == nil {
+		return nil, false
+	}
+
+	params := Params{}
+
+	for index, value := range hostMatch[1:] {
+		params[h.names[index]] = value
+	}
+
+	return params, true
+}
+
+func stripHostPort(host string) string {
+	if !strings.Contains(host, ":") {
+		return host
+	}
+
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		return hostname
+	}
+
+	return host
+}
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"errors"
+	"net"
+	"os"
+)
+
+// Unix domain socket listened on start, see Server.ListenUnix
+type unixSocket struct {
+	path string
+	mode os.FileMode
+}
+
+// Serve on listener, could be called several times to serve the same routes on several listeners.
+// When any listener is set Host and Port are not listened
+func (s *Server) Listen(listener net.Listener) *Server {
+	if s.inited {
+		panic("Should set Listen before Server.Init()")
+	}
+
+	s.listeners = append(s.listeners, listener)
+
+	return s
+}
+
+// Serve on Unix domain socket at path created on start with file mode, stale socket file
+// left at path is removed. Socket file is removed when server is stopped, see Server.Listen
+func (s *Server) ListenUnix(path string, mode os.FileMode) *Server {
+	if s.inited {
+		panic("Should set ListenUnix before Server.Init()")
+	}
+
+	s.unixSockets = append(s.unixSockets, unixSocket{path, mode})
+
+	return s
+}
+
+// Open Unix domain socket, socket file is removed when listener is closed
+func listenUnix(socket unixSocket) (net.Listener, error) {
+	if info, err := os.Lstat(socket.path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, errors.New("file exists and is not a socket: " + socket.path)
+		}
+
+		if err := os.Remove(socket.path); err != nil {
+			return nil, err
+		}
+	}
+
+	listener, err := net.Listen("unix", socket.path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.Chmod(socket.path, socket.mode); err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	return listener, nil
+}
+
+// Get listeners to serve on, opens Unix sockets and Host:Port when no listener is set.
+// Listeners opened here are closed when any of them fails
+func (s *Server) listen() ([]net.Listener, error) {
+	listeners := append([]net.Listener{}, s.listeners...)
+	opened := []net.Listener{}
+
+	fail := func(err error) ([]net.Listener, error) {
+		for _, listener := range opened {
+			listener.Close()
+		}
+
+		return nil, err
+	}
+
+	for _, socket := range s.unixSockets {
+		listener, err := listenUnix(socket)
+
+		if err != nil {
+			return fail(err)
+		}
+
+		opened = append(opened, listener)
+	}
+
+	if len(listeners) == 0 && len(opened) == 0 {
+		listener, err := net.Listen("tcp", s.address())
+
+		if err != nil {
+			return fail(err)
+		}
+
+		opened = append(opened, listener)
+	}
+
+	return append(listeners, opened...), nil
+}
@@ -1,6 +1,10 @@
 package server
 
-import "regexp"
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
 
 type Matching struct {
 	RequestedPath string
@@ -14,70 +18,174 @@ type MatchingExecuteOptions struct {
 	IsRegexp    bool
 }
 
-func (m *Matching) ParseParams() Params {
-	routeParamsRegexp := regexp.MustCompile(`(\:[a-zA-Z]+)`)
-
-	paramRegexp := "(.+)"
+// Compiled form of handler path, built once and reused for every requested path
+type Matcher struct {
+	HandlerPath string
+	options     MatchingExecuteOptions
+	regexp      *regexp.Regexp
+	params      *regexp.Regexp
+	paramNames  []string
+	validators  []*regexp.Regexp
+}
 
-	routeParamsMatch := routeParamsRegexp.FindAllSubmatchIndex([]byte(m.HandlerPath), -1)
+// Compile handler path with options, returns error when handler path is not valid RegExp.
+// When ParseParams is true, :name matches exactly one path segment or its part before static suffix
+// and *name matches rest of path, both could be followed by <constraint> which value should satisfy
+func CompileMatching(handlerPath string, options MatchingExecuteOptions) (*Matcher, error) {
+	matcher := &Matcher{
+		HandlerPath: handlerPath,
+		options:     options,
+	}
 
-	if len(routeParamsMatch) > 0 {
-		routePathRegexp := m.HandlerPath
+	if options.IsRegexp {
+		r, err := regexp.Compile(handlerPath)
 
-		routeParamNames := []string{}
+		if err != nil {
+			return nil, fmt.Errorf("invalid regexp path %q: %w", handlerPath, err)
+		}
 
-		diff := 0
+		matcher.regexp = r
+	}
 
-		for _, indexes := range routeParamsMatch {
-			name := routePathRegexp[indexes[2]+diff+1 : indexes[3]+diff]
+	if options.ParseParams {
+		routePathRegexp := ""
 
-			routeParamNames = append(routeParamNames, name)
+		tokens, err := tokenizePattern(handlerPath, true)
 
-			routePathRegexp = routePathRegexp[:indexes[0]+diff] + paramRegexp + routePathRegexp[indexes[1]+diff:]
+		if err != nil {
+			return nil, err
+		}
 
-			diff += len(paramRegexp) - (indexes[3] - indexes[2])
+		if err := validatePattern(handlerPath, tokens); err != nil {
+			return nil, err
 		}
 
-		pathRegexp := regexp.MustCompile(`^` + routePathRegexp + `$`)
+		for _, token := range tokens {
+			switch token.kind {
+			case nodeStatic:
+				routePathRegexp += regexp.QuoteMeta(token.value)
+				continue
+			case nodeParam:
+				routePathRegexp += "([^/]+)"
+			case nodeCatchAll:
+				routePathRegexp += "(.*)"
+			}
 
-		pathMatch := pathRegexp.FindAllSubmatchIndex([]byte(m.RequestedPath), -1)
+			var validator *regexp.Regexp
 
-		if len(pathMatch) > 0 {
-			params := Params{}
+			if token.constraint != "" {
+				if validator, err = compileConstraint(token.constraint); err != nil {
+					return nil, fmt.Errorf("invalid params path %q: %w", handlerPath, err)
+				}
+			}
 
-			for index := 2; index < len(pathMatch[0]); index += 2 {
-				matchIndexes := pathMatch[0][index : index+2]
-				value := m.RequestedPath[matchIndexes[0]:matchIndexes[1]]
+			matcher.paramNames = append(matcher.paramNames, token.value)
+			matcher.validators = append(matcher.validators, validator)
+		}
+
+		if len(matcher.paramNames) > 0 {
+			r, err := regexp.Compile(`^` + routePathRegexp + `$`)
 
-				params[routeParamNames[(index/2)-1]] = value
+			if err != nil {
+				return nil, fmt.Errorf("invalid params path %q: %w", handlerPath, err)
 			}
 
-			return params
+			matcher.params = r
 		}
 	}
 
-	return nil
+	return matcher, nil
 }
 
-func (m *Matching) Execute(options MatchingExecuteOptions) (bool, Params) {
-	if options.IsRegexp {
-		r := regexp.MustCompile(m.HandlerPath)
-		if r.Match([]byte(m.RequestedPath)) {
-			return true, nil
+// Match requested path, returns params when handler path has them and ParseParams is enabled.
+// For RegExp handler path params hold named groups by their names and unnamed groups by their
+// positions among unnamed groups starting from "0"
+func (m *Matcher) Match(requestedPath string) (bool, Params) {
+	if m.regexp != nil {
+		if params := m.parseGroups(requestedPath); params != nil {
+			return true, params
 		}
 	}
 
-	if options.ParseParams {
-		params := m.ParseParams()
-
-		if params != nil {
-			return true, params
-		}
+	if params := m.parseParams(requestedPath); params != nil {
+		return true, params
 	}
 
-	if m.HandlerPath == m.RequestedPath {
+	if m.HandlerPath == requestedPath {
 		return true, nil
 	}
 
 	return false, nil
 }
+
+func (m *Matcher) parseGroups(requestedPath string) Params {
+	pathMatch := m.regexp.FindStringSubmatchIndex(requestedPath)
+
+	if pathMatch == nil {
+		return nil
+	}
+
+	params := Params{}
+	position := 0
+
+	for index, name := range m.regexp.SubexpNames()[1:] {
+		if name == "" {
+			name = strconv.Itoa(position)
+			position++
+		}
+
+		start, end := pathMatch[(index+1)*2], pathMatch[(index+1)*2+1]
+
+		if start < 0 {
+			continue
+		}
+
+		params[name] = requestedPath[start:end]
+	}
+
+	return params
+}
+
+func (m *Matcher) parseParams(requestedPath string) Params {
+	if m.params == nil {
+		return nil
+	}
+
+	pathMatch := m.params.FindStringSubmatch(requestedPath)
+
+	if pathMatch == nil {
+		return nil
+	}
+
+	params := Params{}
+
+	for index, value := range pathMatch[1:] {
+		if validator := m.validators[index]; validator != nil && !validator.MatchString(value) {
+			return nil
+		}
+
+		params[m.paramNames[index]] = value
+	}
+
+	return params
+}
+
+func mustCompileMatching(handlerPath string, options MatchingExecuteOptions) *Matcher {
+	matcher, err := CompileMatching(handlerPath, options)
+
+	if err != nil {
+		panic(err)
+	}
+
+	return matcher
+}
+
+// Parse params of requested path, compiles handler path on each call, prefer CompileMatching for repeated matching
+func (m *Matching) ParseParams() Params {
+	return mustCompileMatching(m.HandlerPath, MatchingExecuteOptions{ParseParams: true}).parseParams(m.RequestedPath)
+}
+
+// Execute matching, compiles handler path on each call, prefer CompileMatching for repeated matching
+func (m *Matching) Execute(options MatchingExecuteOptions) (bool, Params) {
+	return mustCompileMatching(m.HandlerPath, options).Match(m.RequestedPath)
+}
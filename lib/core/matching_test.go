@@ -92,4 +92,207 @@ func TestMatchingExecute(t *testing.T) {
 			t.Fatalf("%v expected not match", matching)
 		}
 	})
+	t.Run("Check params match exactly one segment", func(t *testing.T) {
+		matching := Matching{
+			RequestedPath: "/users/1/delete/anything",
+			HandlerPath:   "/users/:id",
+		}
+
+		result, _ := matching.Execute(MatchingExecuteOptions{
+			ParseParams: true,
+			IsRegexp:    false,
+		})
+
+		if result == true {
+			t.Fatalf("%v expected not match", matching)
+		}
+	})
+
+	t.Run("Check params names with digits and underscores", func(t *testing.T) {
+		matching := Matching{
+			RequestedPath: "/users/1/posts/2",
+			HandlerPath:   "/users/:user_id/posts/:post2",
+		}
+
+		result, params := matching.Execute(MatchingExecuteOptions{
+			ParseParams: true,
+			IsRegexp:    false,
+		})
+
+		if result != true {
+			t.Fatalf("%v expected to match", matching)
+		}
+
+		expectedParams := Params{
+			"user_id": "1",
+			"post2":   "2",
+		}
+
+		if !reflect.DeepEqual(params, expectedParams) {
+			t.Fatalf("%v expected to be equal %v", params, expectedParams)
+		}
+	})
+
+	t.Run("Check catch-all matching", func(t *testing.T) {
+		matching := Matching{
+			RequestedPath: "/files/1/images/a.png",
+			HandlerPath:   "/files/:id/*rest",
+		}
+
+		result, params := matching.Execute(MatchingExecuteOptions{
+			ParseParams: true,
+			IsRegexp:    false,
+		})
+
+		if result != true {
+			t.Fatalf("%v expected to match", matching)
+		}
+
+		expectedParams := Params{
+			"id":   "1",
+			"rest": "images/a.png",
+		}
+
+		if !reflect.DeepEqual(params, expectedParams) {
+			t.Fatalf("%v expected to be equal %v", params, expectedParams)
+		}
+	})
+
+	t.Run("Check static parts are not treated as regexp", func(t *testing.T) {
+		matching := Matching{
+			RequestedPath: "/indexXjson/1",
+			HandlerPath:   "/index.json/:id",
+		}
+
+		result, _ := matching.Execute(MatchingExecuteOptions{
+			ParseParams: true,
+			IsRegexp:    false,
+		})
+
+		if result == true {
+			t.Fatalf("%v expected not match", matching)
+		}
+	})
+}
+
+func TestCompileMatching(t *testing.T) {
+	t.Run("Check compiled matcher reused for different paths", func(t *testing.T) {
+		matcher, err := CompileMatching("/index/:id", MatchingExecuteOptions{
+			ParseParams: true,
+		})
+
+		if err != nil {
+			t.Fatalf("Got error while compiling: %v", err)
+		}
+
+		for _, id := range []string{"1", "2"} {
+			result, params := matcher.Match("/index/" + id)
+
+			if result != true {
+				t.Fatalf("/index/%v expected to match", id)
+			}
+
+			if params["id"] != id {
+				t.Fatalf("%v expected to be equal %v", params["id"], id)
+			}
+		}
+	})
+
+	t.Run("Check params constraints", func(t *testing.T) {
+		matcher, err := CompileMatching("/orders/:id<int>", MatchingExecuteOptions{
+			ParseParams: true,
+		})
+
+		if err != nil {
+			t.Fatalf("Got error while compiling: %v", err)
+		}
+
+		if result, _ := matcher.Match("/orders/42"); result != true {
+			t.Fatal("/orders/42 expected to match")
+		}
+
+		if result, _ := matcher.Match("/orders/abc"); result == true {
+			t.Fatal("/orders/abc expected not match")
+		}
+
+		if _, err := CompileMatching("/orders/:id<[0-9>", MatchingExecuteOptions{ParseParams: true}); err == nil {
+			t.Fatal("Expected error for invalid constraint")
+		}
+	})
+
+	t.Run("Check params with static suffix and invalid wildcards", func(t *testing.T) {
+		matcher, err := CompileMatching("/files/:name.json", MatchingExecuteOptions{ParseParams: true})
+
+		if err != nil {
+			t.Fatalf("Got error while compiling: %v", err)
+		}
+
+		if result, params := matcher.Match("/files/report.json"); !result || params["name"] != "report" {
+			t.Fatalf("%v %v expected to match with name %v", result, params, "report")
+		}
+
+		for _, path := range []string{"/files/:name:ext", "/files/:name.:ext", "/files/*path/index"} {
+			if _, err := CompileMatching(path, MatchingExecuteOptions{ParseParams: true}); err == nil {
+				t.Fatalf("%v expected to be invalid", path)
+			}
+		}
+	})
+
+	t.Run("Check invalid regexp returns error", func(t *testing.T) {
+		_, err := CompileMatching("^/index/(.+$", MatchingExecuteOptions{
+			IsRegexp: true,
+		})
+
+		if err == nil {
+			t.Fatal("Expected error for invalid regexp")
+		}
+	})
+}
+
+func TestMatcherRegexpGroups(t *testing.T) {
+	t.Run("Check named and positional groups", func(t *testing.T) {
+		matcher, err := CompileMatching(`^/files/(?P<path>.+)\.([a-z]+)$`, MatchingExecuteOptions{
+			IsRegexp: true,
+		})
+
+		if err != nil {
+			t.Fatalf("Got error while compiling: %v", err)
+		}
+
+		result, params := matcher.Match("/files/images/a.png")
+
+		if result != true {
+			t.Fatalf("%v expected to match", matcher.HandlerPath)
+		}
+
+		expectedParams := Params{
+			"path": "images/a",
+			"0":    "png",
+		}
+
+		if !reflect.DeepEqual(params, expectedParams) {
+			t.Fatalf("%v expected to be equal %v", params, expectedParams)
+		}
+	})
+
+	t.Run("Check positional groups numbered from zero", func(t *testing.T) {
+		matcher, err := CompileMatching(`^/(\d+)/(?:x|y)/(\w+)(/debug)?$`, MatchingExecuteOptions{
+			IsRegexp: true,
+		})
+
+		if err != nil {
+			t.Fatalf("Got error while compiling: %v", err)
+		}
+
+		result, params := matcher.Match("/42/x/name")
+
+		expectedParams := Params{
+			"0": "42",
+			"1": "name",
+		}
+
+		if !result || !reflect.DeepEqual(params, expectedParams) {
+			t.Fatalf("%v %v expected to be equal %v", result, params, expectedParams)
+		}
+	})
 }
@@ -1,23 +1,47 @@
 package server
 
-import "exporter-dev/http-server/lib/utils"
+import (
+	"exporter-dev/http-server/lib/utils"
+	"fmt"
+	"sort"
+	"strings"
+)
 
 type Middleware struct {
-	Method      []string
-	Handler     MiddlewareHandler
-	Path        []string
-	ParseParams bool
-	IsRegexp    bool
+	Method       []string
+	Handler      MiddlewareHandler
+	Wrapping     WrappingMiddlewareHandler
+	Path         []string
+	ExcludedPath []string
+	RouteName    []string
+	Priority     int
+	ParseParams  bool
+	IsRegexp     bool
+	matchers     []*Matcher
+	excluded     []*Matcher
+	prefix       string
 }
 
 type MiddlewareHandler func(request *Request, controller *Controller) (skip bool, err error)
 
+// Middleware handler wrapping the rest of the chain, next executes following middlewares and route handler.
+// Response sent by route handler could be observed and changed after next returns, unless it was streamed.
+// Error or panic of route handler is returned by next, 500 is sent when it's returned further
+type WrappingMiddlewareHandler func(request *Request, controller *Controller, next func() error) error
+
 func NewMiddleware(handler MiddlewareHandler) *Middleware {
 	return &Middleware{
 		Handler: handler,
 	}
 }
 
+// Creates new Middleware wrapping following middlewares and route handler
+func NewWrappingMiddleware(handler WrappingMiddlewareHandler) *Middleware {
+	return &Middleware{
+		Wrapping: handler,
+	}
+}
+
 func (m *Middleware) SetIsRegexp(value bool) *Middleware {
 	m.IsRegexp = value
 
@@ -36,109 +60,275 @@ func (m *Middleware) AddPath(path string) *Middleware {
 	return m
 }
 
-type Middlewaring struct {
-	Middlewares []Middleware
+// Add path for which middleware is not executed even when it matches other paths or route names
+func (m *Middleware) ExcludePath(path string) *Middleware {
+	m.ExcludedPath = append(m.ExcludedPath, path)
+
+	return m
 }
 
-func (m *Middlewaring) Execute(request *Request, controller *Controller) (skip bool, err error) {
-	method := request.Method
-	path := request.Path
+// Add method for which middleware is executed, every method when none is added
+func (m *Middleware) AddMethod(method string) *Middleware {
+	m.Method = append(m.Method, method)
 
-	var middlewares []Middleware
+	return m
+}
 
-	for index, middleware := range m.Middlewares {
-		if len(middleware.Method) > 0 &&
-			!utils.Some(middleware.Method, func(item string, index int) bool {
-				return item == method
-			}) {
-			continue
-		}
+// Add name of route for which middleware is executed, see Route.Name
+func (m *Middleware) AddRouteName(name string) *Middleware {
+	m.RouteName = append(m.RouteName, name)
+
+	return m
+}
 
-		if len(middleware.Method) == 0 && len(middleware.Path) == 0 {
-			middlewares = append(middlewares, middleware)
+// Set priority of middleware among middlewares of Server or of the same group, middlewares with
+// higher priority are executed first, with the same priority in order of registration
+func (m *Middleware) SetPriority(priority int) *Middleware {
+	m.Priority = priority
 
-			continue
-		}
+	return m
+}
+
+// Get handler wrapping the rest of the chain, Handler is adapted to call next
+// only when it doesn't skip and doesn't fail
+func (m *Middleware) wrapping() WrappingMiddlewareHandler {
+	if m.Wrapping != nil {
+		return m.Wrapping
+	}
 
-		var validate bool
+	return func(request *Request, controller *Controller, next func() error) error {
+		skip, err := m.Handler(request, controller)
 
-		if utils.Some(middleware.Path, func(item string, index int) bool {
-			return item == request.Route.Path
-		}) {
-			validate = true
+		if err != nil || skip {
+			return err
 		}
 
-		if !validate {
-		inner:
-			for _, handlerPath := range middleware.Path {
-				matching := &Matching{
-					RequestedPath: path,
-					HandlerPath:   handlerPath,
-				}
-
-				result, _ := matching.Execute(
-					MatchingExecuteOptions{
-						IsRegexp:    middleware.IsRegexp,
-						ParseParams: middleware.ParseParams,
-					},
-				)
-
-				if result {
-					validate = true
-					break inner
-				}
-			}
+		return next()
+	}
+}
+
+// Compile paths of middleware, returns error when any path is not valid
+func (m *Middleware) compile() (err error) {
+	if m.matchers, err = compilePaths(m.Path, m.IsRegexp, m.ParseParams); err != nil {
+		return err
+	}
+
+	m.excluded, err = compilePaths(m.ExcludedPath, m.IsRegexp, m.ParseParams)
+
+	return err
+}
+
+func compilePaths(paths []string, isRegexp bool, parseParams bool) ([]*Matcher, error) {
+	matchers := make([]*Matcher, 0, len(paths))
+
+	for _, path := range paths {
+		matcher, err := CompileMatching(path, MatchingExecuteOptions{
+			IsRegexp:    isRegexp,
+			ParseParams: parseParams,
+		})
+
+		if err != nil {
+			return nil, err
 		}
 
-		if validate {
-			middlewares = append(middlewares, m.Middlewares[index])
+		matchers = append(matchers, matcher)
+	}
+
+	return matchers, nil
+}
+
+// Check if middleware is executed for request, by method, excluded paths, paths and route names.
+// Middleware without paths and route names is executed for every path, paths should be compiled before
+func (m *Middleware) matches(request *Request) bool {
+	if len(m.Method) > 0 && !utils.Some(m.Method, func(item string, index int) bool {
+		return item == request.Method
+	}) {
+		return false
+	}
+
+	path, routePath := request.Path, ""
+
+	if request.Route != nil {
+		routePath = request.Route.Path
+	}
+
+	// Paths of middlewares of mounted Server are relative to mount prefix
+	if m.prefix != "" {
+		path, routePath = stripPrefix(path, m.prefix), stripPrefix(routePath, m.prefix)
+	}
+
+	if matchPaths(m.ExcludedPath, m.excluded, path, routePath) {
+		return false
+	}
+
+	if len(m.Path) == 0 && len(m.RouteName) == 0 {
+		return true
+	}
+
+	if request.Route != nil && request.Route.name != "" && utils.Some(m.RouteName, func(item string, index int) bool {
+		return item == request.Route.name
+	}) {
+		return true
+	}
+
+	return matchPaths(m.Path, m.matchers, path, routePath)
+}
+
+// Get path without prefix, "/" when path equals prefix
+func stripPrefix(path string, prefix string) string {
+	if path == prefix {
+		return "/"
+	}
+
+	return strings.TrimPrefix(path, prefix)
+}
+
+// Get copies of middlewares with paths relative to prefix
+func prefixMiddlewares(middlewares []Middleware, prefix string) []Middleware {
+	prefixed := make([]Middleware, len(middlewares))
+
+	for index, middleware := range middlewares {
+		middleware.prefix = prefix + middleware.prefix
+		prefixed[index] = middleware
+	}
+
+	return prefixed
+}
+
+// Check if route path equals any of paths or requested path matches any of matchers
+func matchPaths(paths []string, matchers []*Matcher, path string, routePath string) bool {
+	if routePath != "" && utils.Some(paths, func(item string, index int) bool {
+		return item == routePath
+	}) {
+		return true
+	}
+
+	for _, matcher := range matchers {
+		if result, _ := matcher.Match(path); result {
+			return true
 		}
 	}
 
-	return m.handle(middlewares, request, controller)
+	return false
 }
 
-type ConcurrencyMiddlewareResult struct {
-	skip bool
-	err  error
+// Middlewares of Server compiled by NewMiddlewaring
+type Middlewaring struct {
+	Middlewares []Middleware
+	groups      map[*Group][]Middleware
+}
+
+// Copy middlewares sorted by priority and compile paths of every middleware
+func compileMiddlewares(middlewares []Middleware) ([]Middleware, error) {
+	compiled := make([]Middleware, len(middlewares))
+
+	copy(compiled, middlewares)
+
+	sort.SliceStable(compiled, func(i int, j int) bool {
+		return compiled[i].Priority > compiled[j].Priority
+	})
+
+	for index := range compiled {
+		if err := compiled[index].compile(); err != nil {
+			return nil, err
+		}
+	}
+
+	return compiled, nil
 }
 
-func handleConcurrencyMiddleware(middleware *Middleware, request *Request, controller *Controller, output chan<- ConcurrencyMiddlewareResult) {
-	skip, err := middleware.Handler(request, controller)
+// Creates new Middlewaring, sorts middlewares by priority and compiles paths of every middleware
+func NewMiddlewaring(middlewares []Middleware) (*Middlewaring, error) {
+	compiled, err := compileMiddlewares(middlewares)
 
-	result := ConcurrencyMiddlewareResult{
-		skip,
-		err,
+	if err != nil {
+		return nil, err
 	}
 
-	output <- result
+	return &Middlewaring{
+		Middlewares: compiled,
+	}, nil
 }
 
-func handleMiddleware(middleware *Middleware, request *Request, controller *Controller) (skip bool, err error) {
-	output := make(chan ConcurrencyMiddlewareResult)
-	go handleConcurrencyMiddleware(middleware, request, controller, output)
+// Execute middlewares matching request, middlewares of route groups are executed
+// after global ones, from the outermost group to the innermost. Returns skip when
+// any middleware didn't pass request further
+func (m *Middlewaring) Execute(request *Request, controller *Controller) (skip bool, err error) {
+	var reached bool
+
+	err = m.Wrap(request, controller, func() error {
+		reached = true
 
-	result := <-output
+		return nil
+	})
 
-	return result.skip, result.err
+	return !reached && err == nil, err
 }
 
-func (m *Middlewaring) handle(middlewares []Middleware, request *Request, controller *Controller) (skip bool, err error) {
-	if len(middlewares) > 0 {
-		current := middlewares[0]
+// Execute middlewares matching request around handler in order of Middlewaring.Execute,
+// handler is executed by the innermost middleware calling next
+func (m *Middlewaring) Wrap(request *Request, controller *Controller, handler func() error) error {
+	middlewares := m.match(request)
+
+	var next func(index int) error
 
-		skip, err := handleMiddleware(&current, request, controller)
+	next = func(index int) error {
+		// Chain is stopped when client is gone or request deadline is exceeded
+		if err := request.Ctx().Err(); err != nil {
+			return err
+		}
 
-		if skip {
-			return true, nil
+		if index == len(middlewares) {
+			return handler()
 		}
 
-		if err == nil {
-			return m.handle(middlewares[1:], request, controller)
+		return executeMiddleware(&middlewares[index], request, controller, func() error {
+			return next(index + 1)
+		})
+	}
+
+	return next(0)
+}
+
+// Execute middleware, panic is recovered and returned as error to previous middleware
+func executeMiddleware(middleware *Middleware, request *Request, controller *Controller, next func() error) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("middleware panic: %v", recovered)
 		}
+	}()
+
+	return middleware.wrapping()(request, controller, next)
+}
 
-		return false, err
+// Get global and group middlewares matching request, middlewares of groups are
+// the ones compiled by Server.Prepare
+func (m *Middlewaring) match(request *Request) []Middleware {
+	middlewares := filterMiddlewares(m.Middlewares, request)
+
+	if request.Route != nil {
+		for _, group := range request.Route.Group.chain() {
+			middlewares = append(middlewares, filterMiddlewares(m.groups[group], request)...)
+		}
+
+		// Timeout of route wraps only route handler
+		if request.Route.Timeout > 0 {
+			middlewares = append(middlewares, *NewTimeoutMiddleware(request.Route.Timeout, request.Route.TimeoutStatus))
+		}
+	}
+
+	return middlewares
+}
+
+// Filter middlewares by method, path and route name of request
+func filterMiddlewares(candidates []Middleware, request *Request) []Middleware {
+	var middlewares []Middleware
+
+	for index := range candidates {
+		if candidates[index].matches(request) {
+			middlewares = append(middlewares, candidates[index])
+		}
 	}
 
-	return false, nil
+	return middlewares
 }
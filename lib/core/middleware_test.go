@@ -0,0 +1,292 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMiddlewaringWrap(t *testing.T) {
+	t.Run("Should change response after route handler", func(t *testing.T) {
+		instance := NewServer()
+
+		instance.Use(*NewWrappingMiddleware(func(request *Request, controller *Controller, next func() error) error {
+			if err := next(); err != nil {
+				return err
+			}
+
+			controller.Header.Add("X-Status", http.StatusText(*controller.Response.Status))
+			controller.Status(http.StatusAccepted)
+			*controller.Response.Content = []byte(strings.ToUpper(string(*controller.Response.Content)))
+
+			return nil
+		}))
+
+		instance.Get(*NewRoute("/index", func(request *Request, controller *Controller) error {
+			return controller.Send("index")
+		}))
+
+		recorder := serveTestRequest(t, instance, GET, "/index")
+
+		if recorder.Code != http.StatusAccepted {
+			t.Fatalf("%v expected to be %v", recorder.Code, http.StatusAccepted)
+		}
+
+		if recorder.Body.String() != "INDEX" {
+			t.Fatalf("%v expected to be %v", recorder.Body.String(), "INDEX")
+		}
+
+		if status := recorder.Header().Get("X-Status"); status != "OK" {
+			t.Fatalf("%v expected to be %v", status, "OK")
+		}
+	})
+
+	t.Run("Should execute wrapping and skip-style middlewares in order", func(t *testing.T) {
+		var calls []string
+
+		newWrapping := func(mark string) Middleware {
+			return *NewWrappingMiddleware(func(request *Request, controller *Controller, next func() error) error {
+				calls = append(calls, mark+":before")
+				err := next()
+				calls = append(calls, mark+":after")
+
+				return err
+			})
+		}
+
+		instance := NewServer()
+
+		instance.Use(newWrapping("outer"))
+		instance.Use(*NewMiddleware(func(request *Request, controller *Controller) (skip bool, err error) {
+			calls = append(calls, "skip-style")
+
+			if request.Path == "/private" {
+				controller.Status(http.StatusUnauthorized)
+
+				return true, controller.Send("unauthorized")
+			}
+
+			return false, nil
+		}))
+
+		instance.Group("/").Use(newWrapping("group")).Get(
+			*NewRoute("public", func(request *Request, controller *Controller) error {
+				calls = append(calls, "handler")
+				return controller.Send("public")
+			}),
+			*NewRoute("private", func(request *Request, controller *Controller) error {
+				calls = append(calls, "handler")
+				return controller.Send("private")
+			}),
+		)
+
+		cases := []struct {
+			target string
+			status int
+			body   string
+			calls  []string
+		}{
+			{"/public", http.StatusOK, "public", []string{"outer:before", "skip-style", "group:before", "handler", "group:after", "outer:after"}},
+			{"/private", http.StatusUnauthorized, "unauthorized", []string{"outer:before", "skip-style", "outer:after"}},
+		}
+
+		for _, c := range cases {
+			calls = nil
+
+			recorder := serveTestRequest(t, instance, GET, c.target)
+
+			if recorder.Code != c.status || recorder.Body.String() != c.body {
+				t.Fatalf("%v %v expected to be %v %v", recorder.Code, recorder.Body.String(), c.status, c.body)
+			}
+
+			if !reflect.DeepEqual(calls, c.calls) {
+				t.Fatalf("%v expected to be equal %v", calls, c.calls)
+			}
+		}
+	})
+
+	t.Run("Should return route handler error to middleware", func(t *testing.T) {
+		var observed error
+
+		instance := NewServer()
+
+		instance.Use(*NewWrappingMiddleware(func(request *Request, controller *Controller, next func() error) error {
+			if observed = next(); observed == nil {
+				return nil
+			}
+
+			*controller.Response.Content = nil
+			controller.Header.Add("X-After", "true")
+			controller.Status(http.StatusBadGateway)
+
+			return controller.Send(observed.Error())
+		}))
+
+		instance.Get(
+			*NewRoute("/failed", func(request *Request, controller *Controller) error {
+				controller.Send("partial")
+
+				return errors.New("failed")
+			}),
+			*NewRoute("/panic", func(request *Request, controller *Controller) error {
+				panic("broken")
+			}),
+		)
+
+		for target, expected := range map[string]string{"/failed": "failed", "/panic": "route handler panic: broken"} {
+			recorder := serveTestRequest(t, instance, GET, target)
+
+			if observed == nil || observed.Error() != expected {
+				t.Fatalf("%v expected to be %v", observed, expected)
+			}
+
+			if recorder.Code != http.StatusBadGateway || recorder.Body.String() != expected || recorder.Header().Get("X-After") != "true" {
+				t.Fatalf("%v %v %v expected to be %v %v with header", recorder.Code, recorder.Body.String(), recorder.Header(), http.StatusBadGateway, expected)
+			}
+		}
+	})
+
+	t.Run("Should discard sent content when middleware fails", func(t *testing.T) {
+		instance := NewServer()
+
+		instance.Use(*NewWrappingMiddleware(func(request *Request, controller *Controller, next func() error) error {
+			next()
+
+			return errors.New("failed")
+		}))
+
+		instance.Get(*NewRoute("/index", func(request *Request, controller *Controller) error {
+			return controller.Send("index")
+		}))
+
+		recorder := serveTestRequest(t, instance, GET, "/index")
+
+		if recorder.Code != http.StatusInternalServerError || recorder.Body.String() != http.StatusText(http.StatusInternalServerError) {
+			t.Fatalf("%v %v expected to be %v", recorder.Code, recorder.Body.String(), http.StatusInternalServerError)
+		}
+	})
+}
+
+func TestMiddlewaringExecute(t *testing.T) {
+	t.Run("Should recover middleware panic and return it to previous middleware", func(t *testing.T) {
+		var recovered error
+
+		instance := NewServer()
+
+		instance.Use(*NewWrappingMiddleware(func(request *Request, controller *Controller, next func() error) error {
+			recovered = next()
+
+			return recovered
+		}))
+
+		instance.Use(*NewMiddleware(func(request *Request, controller *Controller) (skip bool, err error) {
+			panic("broken")
+		}))
+
+		instance.Get(*NewRoute("/index", func(request *Request, controller *Controller) error {
+			return controller.Send("index")
+		}))
+
+		recorder := serveTestRequest(t, instance, GET, "/index")
+
+		if recovered == nil || !strings.Contains(recovered.Error(), "broken") {
+			t.Fatalf("%v expected to contain %v", recovered, "broken")
+		}
+
+		if recorder.Code != http.StatusInternalServerError {
+			t.Fatalf("%v expected to be %v", recorder.Code, http.StatusInternalServerError)
+		}
+	})
+
+	t.Run("Should stop chain when request context is canceled", func(t *testing.T) {
+		var handled bool
+
+		instance := NewServer()
+
+		ctx, cancel := context.WithCancel(context.Background())
+
+		instance.Use(*NewMiddleware(func(request *Request, controller *Controller) (skip bool, err error) {
+			cancel()
+			return false, nil
+		}))
+
+		instance.Get(*NewRoute("/index", func(request *Request, controller *Controller) error {
+			handled = true
+			return controller.Send("index")
+		}))
+
+		if err := instance.Prepare(); err != nil {
+			t.Fatalf("Got error while preparing handler: %v", err)
+		}
+
+		recorder := httptest.NewRecorder()
+
+		instance.Handler().ServeHTTP(recorder, httptest.NewRequest(GET, "/index", nil).WithContext(ctx))
+
+		if handled {
+			t.Fatal("Handler expected not to be executed")
+		}
+
+		if recorder.Body.Len() != 0 {
+			t.Fatalf("%v expected to be empty", recorder.Body.String())
+		}
+	})
+}
+
+func TestMiddlewareFilter(t *testing.T) {
+	var calls []string
+
+	newMark := func(mark string) *Middleware {
+		return NewMiddleware(func(request *Request, controller *Controller) (skip bool, err error) {
+			calls = append(calls, mark)
+			return false, nil
+		})
+	}
+
+	handler := func(request *Request, controller *Controller) error {
+		return controller.Send("")
+	}
+
+	instance := NewServer()
+
+	instance.Use(
+		*newMark("post").AddMethod(POST),
+		*newMark("auth").ExcludePath("/health"),
+		*newMark("first").SetPriority(10),
+		*newMark("named").AddRouteName("user.show"),
+		*newMark("users").AddPath("/users/:id").SetParseParams(true).ExcludePath("/users/me"),
+	)
+
+	instance.Get(
+		*NewRoute("/health", handler),
+		*NewRoute("/users/:id", handler).SetParseParams(true).Name("user.show"),
+	)
+	instance.Post(*NewRoute("/health", handler))
+
+	cases := []struct {
+		method string
+		target string
+		calls  []string
+	}{
+		{GET, "/health", []string{"first"}},
+		{POST, "/health", []string{"first", "post"}},
+		{GET, "/users/42", []string{"first", "auth", "named", "users"}},
+		{GET, "/users/me", []string{"first", "auth", "named"}},
+	}
+
+	for _, c := range cases {
+		t.Run("Should filter middlewares for "+c.method+" "+c.target, func(t *testing.T) {
+			calls = nil
+
+			serveTestRequest(t, instance, c.method, c.target)
+
+			if !reflect.DeepEqual(calls, c.calls) {
+				t.Fatalf("%v expected to be equal %v", calls, c.calls)
+			}
+		})
+	}
+}
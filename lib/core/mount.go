@@ -0,0 +1,184 @@
+package server
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Name of catch-all param of mounted routes
+const MOUNT_PARAM = "mountPath"
+
+// Writer of mounted handler, headers set by controller before handler are sent with its status.
+// Writes are dropped after controller is closed, for example by timeout
+type controllerWriter struct {
+	controller *Controller
+	header     http.Header
+}
+
+// Creates new controllerWriter with copy of headers set by controller
+func newControllerWriter(controller *Controller) *controllerWriter {
+	controller.mutex.Lock()
+	defer controller.mutex.Unlock()
+
+	return &controllerWriter{
+		controller: controller,
+		header:     http.Header(controller.headers).Clone(),
+	}
+}
+
+// Get headers sent with status of handler
+func (w *controllerWriter) Header() http.Header {
+	return w.header
+}
+
+// Send headers with status, later statuses are ignored
+func (w *controllerWriter) WriteHeader(status int) {
+	w.controller.mutex.Lock()
+	defer w.controller.mutex.Unlock()
+
+	w.writeHeader(status)
+}
+
+// Send content, status 200 is sent before the first content when not set
+func (w *controllerWriter) Write(content []byte) (int, error) {
+	w.controller.mutex.Lock()
+	defer w.controller.mutex.Unlock()
+
+	if w.controller.closed {
+		return 0, ErrControllerClosed
+	}
+
+	w.writeHeader(http.StatusOK)
+
+	return w.controller.response.Write(content)
+}
+
+// Flush written content to client
+func (w *controllerWriter) Flush() {
+	w.controller.mutex.Lock()
+	defer w.controller.mutex.Unlock()
+
+	if w.controller.closed {
+		return
+	}
+
+	if flusher, ok := w.controller.response.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Write headers and status once, nothing is written after controller is closed
+func (w *controllerWriter) writeHeader(status int) {
+	if w.controller.closed || w.controller.headerSent {
+		return
+	}
+
+	for key, values := range w.header {
+		w.controller.response.Header()[key] = values
+	}
+
+	w.controller.response.WriteHeader(status)
+	w.controller.headerSent = true
+}
+
+// Wrap http.Handler as RouteHandler, handler gets original request with already read body restored
+// and writes to response with headers set by controller before handler. Response is written by
+// handler as streamed one: headers and status set by middlewares after handler are not sent and
+// content sent by them is appended to response of handler
+func WrapHandler(handler http.Handler) RouteHandler {
+	return func(request *Request, controller *Controller) error {
+		original := request.Original.Clone(request.Original.Context())
+
+		original.URL.Path = request.Path
+		original.URL.RawPath = ""
+		original.Body = io.NopCloser(bytes.NewReader(*request.Content))
+
+		writer := newControllerWriter(controller)
+
+		handler.ServeHTTP(writer, original)
+
+		// Status of controller is sent when handler wrote nothing
+		controller.mutex.Lock()
+		writer.writeHeader(controller.status)
+		controller.mutex.Unlock()
+
+		return nil
+	}
+}
+
+// Get routes serving prefix and every path below it by handler, prefix is stripped from request path
+func newMountRoutes(prefix string, handler http.Handler) []Route {
+	prefix = strings.TrimSuffix(prefix, "/")
+
+	routeHandler := func(request *Request, controller *Controller) error {
+		stripped := http.StripPrefix(strings.TrimSuffix(request.Route.Path, "/*"+MOUNT_PARAM), handler)
+
+		return WrapHandler(stripped)(request, controller)
+	}
+
+	routes := []Route{
+		*NewRoute(prefix+"/*"+MOUNT_PARAM, routeHandler).SetParseParams(true),
+	}
+
+	if prefix != "" {
+		routes = append(routes, *NewRoute(prefix, routeHandler))
+	}
+
+	return routes
+}
+
+// Mount http.Handler under prefix for every method from ANY_METHODS, prefix is stripped from request path.
+// Middlewares are executed before handler as for other routes
+func (s *Server) Mount(prefix string, handler http.Handler) {
+	s.Any(newMountRoutes(prefix, handler)...)
+}
+
+// Mount http.Handler under prefix of the group, see Server.Mount
+func (g *Group) Mount(prefix string, handler http.Handler) *Group {
+	return g.Any(newMountRoutes(prefix, handler)...)
+}
+
+// Mount routes of another Server under prefix, its middlewares and groups are executed only
+// for its routes after middlewares of the Server, paths of its middlewares are matched without
+// prefix. Routes registered to sub after mount are ignored. NotFound, HostNotFound and
+// MethodNotAllowed handlers and TrailingSlash of sub are not used, the ones of the Server apply
+func (s *Server) MountServer(prefix string, sub *Server) {
+	mount := s.Group(joinPath(prefix, ""))
+	mount.Use(prefixMiddlewares(sub.middlewares, mount.FullPath())...)
+
+	groups := map[*Group]*Group{}
+
+	var clone func(group *Group) *Group
+
+	clone = func(group *Group) *Group {
+		if group == nil {
+			return mount
+		}
+
+		if cloned, exists := groups[group]; exists {
+			return cloned
+		}
+
+		cloned := s.newGroup(group.Path, clone(group.parent))
+		cloned.Host = group.Host
+		cloned.middlewares = append(cloned.middlewares, prefixMiddlewares(group.middlewares, mount.FullPath())...)
+
+		groups[group] = cloned
+
+		return cloned
+	}
+
+	routes := make([]Route, len(sub.routes))
+
+	for index, route := range sub.routes {
+		route.Path = joinPath(mount.FullPath(), route.Path)
+		route.Group = clone(route.Group)
+		route.Method = append([]string{}, route.Method...)
+
+		routes[index] = route
+	}
+
+	s.routes = append(s.routes, routes...)
+}
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	PARAM_CONSTRAINT_INT  = "int"
+	PARAM_CONSTRAINT_UINT = "uint"
+	PARAM_CONSTRAINT_UUID = "uuid"
+)
+
+// Expressions of built-in param constraints, any other constraint is used as RegExp
+var paramConstraints = map[string]string{
+	PARAM_CONSTRAINT_INT:  `-?[0-9]+`,
+	PARAM_CONSTRAINT_UINT: `[0-9]+`,
+	PARAM_CONSTRAINT_UUID: `[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`,
+}
+
+var uuidValidator = regexp.MustCompile(`^(?:` + paramConstraints[PARAM_CONSTRAINT_UUID] + `)$`)
+
+// Compile param constraint, built-in name or RegExp, which should match the whole param value
+func compileConstraint(constraint string) (*regexp.Regexp, error) {
+	expression, exists := paramConstraints[constraint]
+
+	if !exists {
+		expression = constraint
+	}
+
+	r, err := regexp.Compile(`^(?:` + expression + `)$`)
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid param constraint <%s>: %w", constraint, err)
+	}
+
+	return r, nil
+}
+
+// Get param value by key, returns error when param doesn't exist
+func (p Params) Get(key string) (string, error) {
+	value, exists := p[key]
+
+	if !exists {
+		return "", fmt.Errorf("param %q not found", key)
+	}
+
+	return value, nil
+}
+
+// Get param value by key parsed as int
+func (p Params) Int(key string) (int, error) {
+	value, err := p.Get(key)
+
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := strconv.Atoi(value)
+
+	if err != nil {
+		return 0, fmt.Errorf("param %q is not int: %w", key, err)
+	}
+
+	return result, nil
+}
+
+// Get param value by key parsed as int64
+func (p Params) Int64(key string) (int64, error) {
+	value, err := p.Get(key)
+
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := strconv.ParseInt(value, 10, 64)
+
+	if err != nil {
+		return 0, fmt.Errorf("param %q is not int64: %w", key, err)
+	}
+
+	return result, nil
+}
+
+// Get param value by key validated as UUID, returns value in lower case
+func (p Params) UUID(key string) (string, error) {
+	value, err := p.Get(key)
+
+	if err != nil {
+		return "", err
+	}
+
+	if !uuidValidator.MatchString(value) {
+		return "", fmt.Errorf("param %q is not uuid: %q", key, value)
+	}
+
+	return strings.ToLower(value), nil
+}
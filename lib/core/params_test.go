@@ -0,0 +1,39 @@
+package server
+
+import "testing"
+
+func TestParams(t *testing.T) {
+	params := Params{
+		"id":   "42",
+		"slug": "hello-world",
+		"uuid": "3F2504E0-4F89-11D3-9A0C-0305E82C3301",
+	}
+
+	t.Run("Check Int accessor", func(t *testing.T) {
+		id, err := params.Int("id")
+
+		if err != nil || id != 42 {
+			t.Fatalf("%v expected to be %v, error: %v", id, 42, err)
+		}
+
+		if _, err := params.Int("slug"); err == nil {
+			t.Fatal("Expected error for not int param")
+		}
+
+		if _, err := params.Int("missing"); err == nil {
+			t.Fatal("Expected error for missing param")
+		}
+	})
+
+	t.Run("Check UUID accessor", func(t *testing.T) {
+		uuid, err := params.UUID("uuid")
+
+		if err != nil || uuid != "3f2504e0-4f89-11d3-9a0c-0305e82c3301" {
+			t.Fatalf("%v expected to be lower case uuid, error: %v", uuid, err)
+		}
+
+		if _, err := params.UUID("id"); err == nil {
+			t.Fatal("Expected error for not uuid param")
+		}
+	})
+}
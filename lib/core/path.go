@@ -0,0 +1,80 @@
+package server
+
+import (
+	"net/url"
+	"path"
+	"strings"
+)
+
+type TrailingSlashPolicy int
+
+const (
+	// Path with and without trailing slash are different routes
+	TRAILING_SLASH_STRICT TrailingSlashPolicy = iota
+	// Request is redirected to canonical path of matched route, 301 for GET and HEAD, 308 for other methods
+	TRAILING_SLASH_REDIRECT
+	// Request is served by route with or without trailing slash
+	TRAILING_SLASH_LENIENT
+)
+
+// Clean path from empty, "." and ".." segments, trailing slash is kept
+func cleanPath(requestedPath string) string {
+	if requestedPath == "" {
+		return "/"
+	}
+
+	if requestedPath[0] != '/' {
+		requestedPath = "/" + requestedPath
+	}
+
+	cleaned := path.Clean(requestedPath)
+
+	if strings.HasSuffix(requestedPath, "/") && cleaned != "/" {
+		cleaned += "/"
+	}
+
+	return cleaned
+}
+
+// Get path with trailing slash added or removed
+func toggleTrailingSlash(requestedPath string) string {
+	if requestedPath == "/" {
+		return requestedPath
+	}
+
+	if strings.HasSuffix(requestedPath, "/") {
+		return requestedPath[:len(requestedPath)-1]
+	}
+
+	return requestedPath + "/"
+}
+
+// Get cleaned path for matching routes, when path has encoded chars which could change
+// segments (like %2F) escaped path is used and escaped is true
+func routingPath(requestURL *url.URL) (routing string, escaped bool) {
+	if requestURL.RawPath != "" {
+		return cleanPath(requestURL.EscapedPath()), true
+	}
+
+	return cleanPath(requestURL.Path), false
+}
+
+// Decode params matched against escaped path, values which can't be decoded are kept as is
+func unescapeParams(params Params) {
+	for key, value := range params {
+		if unescaped, err := url.PathUnescape(value); err == nil {
+			params[key] = unescaped
+		}
+	}
+}
+
+// Join prefix with path without duplicate slash, "/" prefix is treated as empty one
+func joinPath(prefix string, path string) string {
+	prefix = strings.TrimSuffix(prefix, "/")
+
+	if prefix != "" && path == "" {
+		return prefix
+	}
+
+	return prefix + path
+}
@@ -1,19 +1,32 @@
 package server
 
 import (
+	"exporter-dev/http-server/lib/utils"
+	"fmt"
 	"log"
 	"net/http"
+	"net/url"
+	"regexp/syntax"
+	"sort"
+	"strings"
+	"time"
 )
 
 type RouteHandler func(request *Request, controller *Controller) error
 
 type Route struct {
-	Method      string
-	Handler     RouteHandler
-	ContentType string
-	Path        string
-	IsRegexp    bool
-	ParseParams bool
+	Method        []string
+	Handler       RouteHandler
+	ContentType   string
+	Path          string
+	IsRegexp      bool
+	ParseParams   bool
+	Host          string
+	Group         *Group
+	Timeout       time.Duration
+	TimeoutStatus int
+	name          string
+	matcher       *Matcher
 }
 
 func NewRoute(path string, handler RouteHandler) *Route {
@@ -23,6 +36,7 @@ func NewRoute(path string, handler RouteHandler) *Route {
 	}
 }
 
+// Prefix path of routes, use Server.Group for groups with own middlewares and nesting
 func NewRouteGroup(path string, routes ...Route) []Route {
 	for index := range routes {
 		routes[index].Path = path + routes[index].Path
@@ -43,73 +57,755 @@ func (r *Route) SetParseParams(value bool) *Route {
 	return r
 }
 
+// Set timeout of route handler, status (503 when 0) is sent when handler is not finished
+// within timeout, see NewTimeoutMiddleware
+func (r *Route) SetTimeout(timeout time.Duration, status int) *Route {
+	r.Timeout = timeout
+	r.TimeoutStatus = status
+
+	return r
+}
+
+// Set host pattern of route, exact like "example.com" or with captures like "{tenant}.example.com"
+func (r *Route) SetHost(host string) *Route {
+	r.Host = host
+
+	return r
+}
+
+// Set name of route, used to build URL of route by Server.URL
+func (r *Route) Name(name string) *Route {
+	r.name = name
+
+	return r
+}
+
+// Build escaped URL by route path with params and query, returns error when param
+// is missing or doesn't satisfy its constraint
+func (r *Route) URL(params Params, query url.Values) (string, error) {
+	if r.IsRegexp {
+		return "", fmt.Errorf("route %q: can't build URL for regexp path %q", r.name, r.Path)
+	}
+
+	tokens, err := tokenizePattern(r.Path, r.ParseParams)
+
+	if err != nil {
+		return "", err
+	}
+
+	var path strings.Builder
+
+	for _, token := range tokens {
+		if token.kind == nodeStatic {
+			path.WriteString(token.value)
+			continue
+		}
+
+		value, exists := params[token.value]
+
+		if !exists || token.kind == nodeParam && value == "" {
+			return "", fmt.Errorf("route %q: param %q is missing", r.name, token.value)
+		}
+
+		if token.constraint != "" {
+			validator, err := compileConstraint(token.constraint)
+
+			if err != nil {
+				return "", err
+			}
+
+			if !validator.MatchString(value) {
+				return "", fmt.Errorf("route %q: param %q doesn't satisfy constraint <%s>: %q", r.name, token.value, token.constraint, value)
+			}
+		}
+
+		if token.kind == nodeParam {
+			path.WriteString(url.PathEscape(value))
+			continue
+		}
+
+		segments := strings.Split(value, "/")
+
+		for index := range segments {
+			segments[index] = url.PathEscape(segments[index])
+		}
+
+		path.WriteString(strings.Join(segments, "/"))
+	}
+
+	if len(query) > 0 {
+		path.WriteString("?" + query.Encode())
+	}
+
+	return path.String(), nil
+}
+
+// Get full path of group route registered in, empty when route has no group
+func (r *Route) GroupPath() string {
+	return r.Group.FullPath()
+}
+
+// Add method which route will handle, duplicates are ignored
+func (r *Route) AddMethod(method string) *Route {
+	if !r.HasMethod(method) {
+		r.Method = append(r.Method, method)
+	}
+
+	return r
+}
+
+// Check if route handles method
+func (r *Route) HasMethod(method string) bool {
+	return utils.Some(r.Method, func(item string, index int) bool {
+		return item == method
+	})
+}
+
 type MatchedRoute struct {
 	Route  *Route
 	Params Params
 }
 
 type Routing struct {
-	Routes []Route
+	Routes           []Route
+	NotFound         *Route
+	MethodNotAllowed *Route
+	Options          *Route
+	table            *routeTable
+	hosts            []*hostRouteTable
+}
+
+// Prefix tree and regexp routes of one host
+type routeTable struct {
+	tree    *node
+	regexps []*Route
+}
+
+// Routes constrained by host pattern, with own handler for requests without matched route
+type hostRouteTable struct {
+	*routeTable
+	host     *hostMatcher
+	notFound *Route
 }
 
-func (r *Routing) Match(method string, path string) *MatchedRoute {
-	for i, route := range r.Routes {
-		if route.Method == method {
-			matching := &Matching{
-				RequestedPath: path,
-				HandlerPath:   route.Path,
+// Default handler for requests without matched route
+func NotFoundHandler(request *Request, controller *Controller) error {
+	controller.Status(http.StatusNotFound)
+
+	return controller.Send(http.StatusText(http.StatusNotFound))
+}
+
+// Default handler for requests which path matches routes only for other methods
+func MethodNotAllowedHandler(request *Request, controller *Controller) error {
+	controller.Status(http.StatusMethodNotAllowed)
+
+	return controller.Send(http.StatusText(http.StatusMethodNotAllowed))
+}
+
+// Default handler for OPTIONS requests without explicit route, Allow header is already added to response
+func OptionsHandler(request *Request, controller *Controller) error {
+	controller.Status(http.StatusNoContent)
+
+	return controller.Send("")
+}
+
+func newRouteTable() *routeTable {
+	return &routeTable{
+		tree: &node{kind: nodeStatic},
+	}
+}
+
+// Creates new Routing and builds prefix tree for routes, regexp routes are compiled and kept aside
+// to be matched in registration order when tree has no route for the request.
+// Routes with Host are kept in separate tables, which are matched before routes without Host.
+// Returns error listing all conflicts when routes are ambiguous or could never be matched
+func NewRouting(routes []Route) (*Routing, error) {
+	routing := &Routing{
+		Routes:           routes,
+		NotFound:         NewRoute("", NotFoundHandler),
+		MethodNotAllowed: NewRoute("", MethodNotAllowedHandler),
+		Options:          NewRoute("", OptionsHandler),
+		table:            newRouteTable(),
+	}
+
+	if err := detectConflicts(routes); err != nil {
+		return nil, err
+	}
+
+	for index := range routing.Routes {
+		route := &routing.Routes[index]
+
+		table := routing.table
+
+		if route.Host != "" {
+			hostTable, err := routing.hostTable(route.Host)
+
+			if err != nil {
+				return nil, err
 			}
 
-			result, params := matching.Execute(MatchingExecuteOptions{
-				ParseParams: route.ParseParams,
-				IsRegexp:    route.IsRegexp,
-			})
+			table = hostTable.routeTable
+		}
 
-			if result {
-				return &MatchedRoute{
-					Route:  &r.Routes[i],
-					Params: params,
+		if err := table.insert(route); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := routing.detectShadowedRegexps(); err != nil {
+		return nil, err
+	}
+
+	return routing, nil
+}
+
+// Check regexp routes matching limited set of paths, route is unreachable when every its path
+// is matched by tree or by regexp route registered before for the same method and host
+func (r *Routing) detectShadowedRegexps() error {
+	var conflicts []string
+
+	tables := []*routeTable{r.table}
+
+	for _, table := range r.hosts {
+		tables = append(tables, table.routeTable)
+	}
+
+	for _, table := range tables {
+		for index, route := range table.regexps {
+			paths, ok := regexpPaths(route.Path)
+
+			if !ok {
+				continue
+			}
+
+			for _, method := range route.Method {
+				var shadowing []string
+
+				for _, path := range paths {
+					other := table.shadowing(method, path, table.regexps[:index])
+
+					if other == nil {
+						shadowing = nil
+						break
+					}
+
+					if !utils.Some(shadowing, func(item string, index int) bool {
+						return item == other.Path
+					}) {
+						shadowing = append(shadowing, other.Path)
+					}
+				}
+
+				if len(shadowing) > 0 {
+					conflicts = append(conflicts, fmt.Sprintf("%s %s%s is unreachable, shadowed by %s", method, route.Host, route.Path, strings.Join(shadowing, ", ")))
+				}
+			}
+		}
+	}
+
+	if len(conflicts) > 0 {
+		return fmt.Errorf("got route conflicts:\n%s", strings.Join(conflicts, "\n"))
+	}
+
+	return nil
+}
+
+// Get route of tree or of previous regexp routes matching path for method, nil when there is none
+func (t *routeTable) shadowing(method string, path string, previous []*Route) *Route {
+	var params Params
+
+	if route := t.tree.find(method, path, &params); route != nil {
+		return route
+	}
+
+	for _, route := range previous {
+		if route.HasMethod(method) {
+			if result, _ := route.matcher.Match(path); result {
+				return route
+			}
+		}
+	}
+
+	return nil
+}
+
+// Signature of route path, routes with the same signature match the same requests whatever
+// their param names are. Routes with the same shape differ only by constraints of wildcards
+type routePattern struct {
+	signature   string
+	shape       string
+	constraints []string
+}
+
+// Get signature of route path, regexp routes matching only one literal path have signature
+// of the static route with this path
+func routeSignature(route *Route) (routePattern, error) {
+	if route.IsRegexp {
+		if literal, ok := literalRegexp(route.Path); ok {
+			return routePattern{signature: literal, shape: literal}, nil
+		}
+
+		return routePattern{signature: "\x00regexp:" + route.Path, shape: "\x00regexp:" + route.Path}, nil
+	}
+
+	tokens, err := tokenizePattern(route.Path, route.ParseParams)
+
+	if err != nil {
+		return routePattern{}, err
+	}
+
+	var signature, shape strings.Builder
+	var constraints []string
+
+	for _, token := range tokens {
+		switch token.kind {
+		case nodeStatic:
+			signature.WriteString(token.value)
+			shape.WriteString(token.value)
+			continue
+		case nodeParam:
+			signature.WriteString("\x00:<" + token.constraint + ">")
+			shape.WriteString("\x00:")
+		case nodeCatchAll:
+			signature.WriteString("\x00*<" + token.constraint + ">")
+			shape.WriteString("\x00*")
+		}
+
+		constraints = append(constraints, token.constraint)
+	}
+
+	return routePattern{signature.String(), shape.String(), constraints}, nil
+}
+
+// Get path matched by regexp, when regexp matches only this one path
+func literalRegexp(pattern string) (string, bool) {
+	paths, ok := regexpPaths(pattern)
+
+	if !ok || len(paths) != 1 {
+		return "", false
+	}
+
+	return paths[0], true
+}
+
+// Limit of paths listed for regexp, regexps matching more paths are not checked for shadowing
+const REGEXP_PATHS_LIMIT = 64
+
+// Get all paths matched by anchored regexp, when regexp matches not more than REGEXP_PATHS_LIMIT paths
+func regexpPaths(pattern string) ([]string, bool) {
+	parsed, err := syntax.Parse(pattern, syntax.Perl)
+
+	if err != nil {
+		return nil, false
+	}
+
+	parsed = parsed.Simplify()
+
+	if parsed.Op != syntax.OpConcat || len(parsed.Sub) < 2 ||
+		parsed.Sub[0].Op != syntax.OpBeginText || parsed.Sub[len(parsed.Sub)-1].Op != syntax.OpEndText {
+		return nil, false
+	}
+
+	return expandRegexp(&syntax.Regexp{Op: syntax.OpConcat, Sub: parsed.Sub[1 : len(parsed.Sub)-1]})
+}
+
+// Get all strings matched by regexp without anchors, repetitions and case folding
+func expandRegexp(parsed *syntax.Regexp) ([]string, bool) {
+	switch parsed.Op {
+	case syntax.OpEmptyMatch:
+		return []string{""}, true
+	case syntax.OpLiteral:
+		if parsed.Flags&syntax.FoldCase != 0 {
+			return nil, false
+		}
+
+		return []string{string(parsed.Rune)}, true
+	case syntax.OpCharClass:
+		var runes []string
+
+		for index := 0; index+1 < len(parsed.Rune); index += 2 {
+			for char := parsed.Rune[index]; char <= parsed.Rune[index+1]; char++ {
+				if len(runes) == REGEXP_PATHS_LIMIT {
+					return nil, false
+				}
+
+				runes = append(runes, string(char))
+			}
+		}
+
+		return runes, true
+	case syntax.OpCapture:
+		return expandRegexp(parsed.Sub[0])
+	case syntax.OpAlternate:
+		var alternatives []string
+
+		for _, sub := range parsed.Sub {
+			expanded, ok := expandRegexp(sub)
+
+			if !ok || len(alternatives)+len(expanded) > REGEXP_PATHS_LIMIT {
+				return nil, false
+			}
+
+			alternatives = append(alternatives, expanded...)
+		}
+
+		return alternatives, true
+	case syntax.OpConcat:
+		combined := []string{""}
+
+		for _, sub := range parsed.Sub {
+			expanded, ok := expandRegexp(sub)
+
+			if !ok || len(combined)*len(expanded) > REGEXP_PATHS_LIMIT {
+				return nil, false
+			}
+
+			var next []string
+
+			for _, prefix := range combined {
+				for _, suffix := range expanded {
+					next = append(next, prefix+suffix)
+				}
+			}
+
+			combined = next
+		}
+
+		return combined, true
+	}
+
+	return nil, false
+}
+
+// Check if constraints at the same positions of routes with the same shape could match the same value,
+// then only route with constraint preferred by tree could be matched for it. Returns overlapping constraints
+func overlappingConstraints(a []string, b []string) (string, string, bool) {
+	for index := range a {
+		if a[index] == "" || b[index] == "" || a[index] == b[index] || disjointConstraints(a[index], b[index]) {
+			continue
+		}
+
+		return a[index], b[index], true
+	}
+
+	return "", "", false
+}
+
+// Check if built-in constraints never match the same value
+func disjointConstraints(a string, b string) bool {
+	numeric := func(constraint string) bool {
+		return constraint == PARAM_CONSTRAINT_INT || constraint == PARAM_CONSTRAINT_UINT
+	}
+
+	return numeric(a) && b == PARAM_CONSTRAINT_UUID || a == PARAM_CONSTRAINT_UUID && numeric(b)
+}
+
+// Detect routes registered for the same method, host and path signature, where only the first
+// registered could be matched, and routes sharing the same name
+func detectConflicts(routes []Route) error {
+	var conflicts []string
+
+	registered := map[string]*Route{}
+	shapes := map[string][]int{}
+	patterns := make([]routePattern, len(routes))
+	names := map[string]*Route{}
+
+	for index := range routes {
+		route := &routes[index]
+
+		pattern, err := routeSignature(route)
+
+		if err != nil {
+			return err
+		}
+
+		patterns[index] = pattern
+
+		for _, method := range route.Method {
+			host := hostSignature(route.Host) + " " + method + " "
+
+			if previous, exists := registered[host+pattern.signature]; exists {
+				unreachable, other := route, previous
+
+				// Regexp routes are matched only when tree has no route
+				if previous.IsRegexp && !route.IsRegexp {
+					unreachable, other = previous, route
+				}
+
+				conflicts = append(conflicts, fmt.Sprintf("%s %s%s is unreachable, conflicts with %s", method, route.Host, unreachable.Path, other.Path))
+				continue
+			}
+
+			registered[host+pattern.signature] = route
+
+			for _, previous := range shapes[host+pattern.shape] {
+				if a, b, overlap := overlappingConstraints(patterns[previous].constraints, pattern.constraints); overlap {
+					conflicts = append(conflicts, fmt.Sprintf("%s %s%s is ambiguous with %s, constraints <%s> and <%s> could match the same value", method, route.Host, route.Path, routes[previous].Path, b, a))
 				}
 			}
+
+			shapes[host+pattern.shape] = append(shapes[host+pattern.shape], index)
+		}
+
+		if route.name == "" {
+			continue
+		}
+
+		if previous, exists := names[route.name]; exists && previous.Path != route.Path {
+			conflicts = append(conflicts, fmt.Sprintf("route name %q is ambiguous, used by %s and %s", route.name, previous.Path, route.Path))
+			continue
 		}
+
+		names[route.name] = route
+	}
+
+	if len(conflicts) > 0 {
+		return fmt.Errorf("got route conflicts:\n%s", strings.Join(conflicts, "\n"))
 	}
 
 	return nil
 }
 
+// Get table of host pattern, creates new one when there is no table for the host
+func (r *Routing) hostTable(host string) (*hostRouteTable, error) {
+	for _, table := range r.hosts {
+		if table.host.pattern == strings.ToLower(host) {
+			return table, nil
+		}
+	}
+
+	matcher, err := compileHost(host)
+
+	if err != nil {
+		return nil, err
+	}
+
+	table := &hostRouteTable{
+		routeTable: newRouteTable(),
+		host:       matcher,
+	}
+
+	r.hosts = append(r.hosts, table)
+
+	// Exact hosts are matched before wildcard ones regardless of registration order
+	sort.SliceStable(r.hosts, func(i, j int) bool {
+		return r.hosts[i].host.moreSpecific(r.hosts[j].host)
+	})
+
+	return table, nil
+}
+
+// Set handler for requests to host without matched route
+func (r *Routing) SetHostNotFound(host string, handler RouteHandler) error {
+	table, err := r.hostTable(host)
+
+	if err != nil {
+		return err
+	}
+
+	table.notFound = NewRoute("", handler)
+	table.notFound.Host = host
+
+	return nil
+}
+
+// Get route for requests to host without matched route, Routing.NotFound when host has no own handler
+func (r *Routing) NotFoundHost(host string) *Route {
+	for _, table := range r.hosts {
+		if table.notFound == nil {
+			continue
+		}
+
+		if _, ok := table.host.Match(host); ok {
+			return table.notFound
+		}
+	}
+
+	return r.NotFound
+}
+
+func (t *routeTable) insert(route *Route) error {
+	if route.IsRegexp {
+		matcher, err := CompileMatching(route.Path, MatchingExecuteOptions{
+			ParseParams: route.ParseParams,
+			IsRegexp:    true,
+		})
+
+		if err != nil {
+			return err
+		}
+
+		route.matcher = matcher
+		t.regexps = append(t.regexps, route)
+
+		return nil
+	}
+
+	return t.tree.insert(route.Path, route.ParseParams, route)
+}
+
+// Match route by method and path ignoring routes with Host, see Routing.MatchHost
+func (r *Routing) Match(method string, path string) (MatchedRoute, bool) {
+	return r.MatchHost(method, "", path)
+}
+
+// Match route by method, host and path, HEAD requests are served by GET routes when there is no HEAD route.
+// Values captured from host are merged into params
+func (r *Routing) MatchHost(method string, host string, path string) (MatchedRoute, bool) {
+	route, found := r.matchHost(method, host, path)
+
+	if !found && method == HEAD {
+		return r.matchHost(GET, host, path)
+	}
+
+	return route, found
+}
+
+func (r *Routing) matchHost(method string, host string, path string) (MatchedRoute, bool) {
+	for _, table := range r.hosts {
+		hostParams, ok := table.host.Match(host)
+
+		if !ok {
+			continue
+		}
+
+		if route, found := table.match(method, path); found {
+			if hostParams != nil {
+				for key, value := range route.Params {
+					hostParams[key] = value
+				}
+
+				route.Params = hostParams
+			}
+
+			return route, true
+		}
+	}
+
+	return r.table.match(method, path)
+}
+
+func (t *routeTable) match(method string, path string) (MatchedRoute, bool) {
+	var params Params
+
+	if route := t.tree.find(method, path, &params); route != nil {
+		return MatchedRoute{
+			Route:  route,
+			Params: params,
+		}, true
+	}
+
+	for _, route := range t.regexps {
+		if route.HasMethod(method) {
+			if result, params := route.matcher.Match(path); result {
+				return MatchedRoute{
+					Route:  route,
+					Params: params,
+				}, true
+			}
+		}
+	}
+
+	return MatchedRoute{}, false
+}
+
+// Get methods of routes matching path ignoring routes with Host, see Routing.AllowedHost
+func (r *Routing) Allowed(path string) []string {
+	return r.AllowedHost("", path)
+}
+
+// Get methods of routes matching host and path, ordered as METHODS with unknown methods at the end.
+// HEAD is allowed with GET and OPTIONS is allowed when path has any route
+func (r *Routing) AllowedHost(host string, path string) []string {
+	methods := map[string]bool{}
+
+	for _, table := range r.hosts {
+		if _, ok := table.host.Match(host); ok {
+			table.allowed(path, methods)
+		}
+	}
+
+	r.table.allowed(path, methods)
+
+	if len(methods) > 0 {
+		methods[OPTIONS] = true
+	}
+
+	if methods[GET] {
+		methods[HEAD] = true
+	}
+
+	var allowed []string
+
+	for _, method := range METHODS {
+		if methods[method] {
+			allowed = append(allowed, method)
+			delete(methods, method)
+		}
+	}
+
+	for method := range methods {
+		allowed = append(allowed, method)
+	}
+
+	sort.Strings(allowed[len(allowed)-len(methods):])
+
+	return allowed
+}
+
+func (t *routeTable) allowed(path string, methods map[string]bool) {
+	t.tree.allowed(path, methods)
+
+	for _, route := range t.regexps {
+		if result, _ := route.matcher.Match(path); result {
+			for _, method := range route.Method {
+				methods[method] = true
+			}
+		}
+	}
+}
+
+// Execute route handler, error or panic of handler is caught with 500 response
 func (r *Routing) Execute(route *MatchedRoute, request *Request, controller *Controller) {
+	if err := r.execute(route, request, controller); err != nil {
+		r.Catch(err, controller, request, controller.response)
+	}
+}
+
+// Execute route handler, panic is recovered and returned as error
+func (r *Routing) execute(route *MatchedRoute, request *Request, controller *Controller) (err error) {
 	var logger = log.New(log.Writer(), "Routing.Execute", log.Flags())
 
-	logger.Printf("Got request: %+v\n", request)
+	logger.Printf("Got request: %+v, route: %q, group: %q\n", request, route.Route.Path, route.Route.GroupPath())
 
 	defer func() {
-		recovered := recover()
-
-		if recovered != nil {
-			r.Catch(recovered, controller, request, controller.response)
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("route handler panic: %v", recovered)
 		}
 	}()
 
 	if route.Route.ContentType != "" {
-		controller.response.Header().Add(HEADER_KEY_CONTENT_TYPE, route.Route.ContentType)
+		controller.Header.Add(HEADER_KEY_CONTENT_TYPE, route.Route.ContentType)
 	}
 
-	err := route.Route.Handler(request, controller)
-
-	if err != nil {
+	if err := route.Route.Handler(request, controller); err != nil {
 		logger.Printf("Got error while execute request handler: %+v\n", err)
-		r.Catch(err, controller, request, controller.response)
-		return
+		return err
 	}
 
+	controller.mutex.Lock()
+	defer controller.mutex.Unlock()
+
 	logger.Printf("Got status: %d, response: %+v", controller.status, string(controller.content))
+
+	return nil
 }
 
 func (r *Routing) Catch(err any, controller *Controller, request *Request, response http.ResponseWriter) {
 	var logger = log.New(log.Writer(), "Routing.Catch", log.Flags())
 
-	response.Header()
-	response.WriteHeader(http.StatusInternalServerError)
-	response.Write([]byte(http.StatusText(http.StatusInternalServerError)))
+	// Content sent before error is discarded, nothing is written when response was finished by timeout
+	controller.abort(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+
 	logger.Printf("Got error while handling request: %+v, response: %+v, error: %+v\n", request, string(controller.content), err)
 }
@@ -0,0 +1,204 @@
+package server
+
+import (
+	"net/url"
+	"testing"
+)
+
+func newTestRouting(t *testing.T, routes []Route) *Routing {
+	routing, err := NewRouting(routes)
+
+	if err != nil {
+		t.Fatalf("Got error while creating routing: %v", err)
+	}
+
+	return routing
+}
+
+func TestRoutingMatch(t *testing.T) {
+	t.Run("Check multi-method route matching", func(t *testing.T) {
+		routing := newTestRouting(t, []Route{
+			*NewRoute("/index", nil).AddMethod(GET).AddMethod(POST),
+		})
+
+		for _, method := range []string{GET, POST} {
+			if _, found := routing.Match(method, "/index"); !found {
+				t.Fatalf("%v /index expected to match", method)
+			}
+		}
+
+		if _, found := routing.Match(DELETE, "/index"); found {
+			t.Fatalf("%v /index expected not match", DELETE)
+		}
+	})
+
+	t.Run("Check Server.Any registers every method except CONNECT and TRACE", func(t *testing.T) {
+		instance := NewServer()
+
+		instance.Any(*NewRoute("/index", nil))
+
+		routing := newTestRouting(t, instance.routes)
+
+		for _, method := range ANY_METHODS {
+			if _, found := routing.Match(method, "/index"); !found {
+				t.Fatalf("%v /index expected to match", method)
+			}
+		}
+
+		for _, method := range []string{CONNECT, TRACE} {
+			if _, found := routing.Match(method, "/index"); found {
+				t.Fatalf("%v /index expected not to match", method)
+			}
+		}
+	})
+
+	t.Run("Check regexp route matching after tree", func(t *testing.T) {
+		routing := newTestRouting(t, []Route{
+			*NewRoute("^/files/.+$", nil).SetIsRegexp(true).AddMethod(GET),
+			*NewRoute("/files/index", nil).AddMethod(GET),
+		})
+
+		route, found := routing.Match(GET, "/files/index")
+
+		if !found || route.Route.Path != "/files/index" {
+			t.Fatalf("%v expected to match /files/index", route.Route)
+		}
+
+		route, found = routing.Match(GET, "/files/other")
+
+		if !found || !route.Route.IsRegexp {
+			t.Fatalf("%v expected to match regexp route", route.Route)
+		}
+	})
+	t.Run("Check invalid patterns reported on creation", func(t *testing.T) {
+		for _, route := range []Route{
+			*NewRoute("^/files/(.+$", nil).SetIsRegexp(true).AddMethod(GET),
+			*NewRoute("/files/*path/index", nil).SetParseParams(true).AddMethod(GET),
+			*NewRoute("/files/:name:ext", nil).SetParseParams(true).AddMethod(GET),
+			*NewRoute("/files/:name.:ext", nil).SetParseParams(true).AddMethod(GET),
+		} {
+			if _, err := NewRouting([]Route{route}); err == nil {
+				t.Fatalf("%v expected to be invalid", route.Path)
+			}
+		}
+	})
+}
+
+func TestRouteURL(t *testing.T) {
+	instance := NewServer()
+
+	instance.Group("/orders").Get(
+		*NewRoute("/:id<int>", nil).SetParseParams(true).Name("order.show"),
+		*NewRoute("/:id<int>/files/*path", nil).SetParseParams(true).Name("order.files"),
+	)
+
+	t.Run("Check URL built with escaped params and query", func(t *testing.T) {
+		link, err := instance.URL("order.show", Params{"id": "42"}, url.Values{"tab": {"a b"}})
+
+		if err != nil || link != "/orders/42?tab=a+b" {
+			t.Fatalf("%v expected to be %v, error: %v", link, "/orders/42?tab=a+b", err)
+		}
+
+		link, err = instance.URL("order.files", Params{"id": "42", "path": "a b/c.txt"}, nil)
+
+		if err != nil || link != "/orders/42/files/a%20b/c.txt" {
+			t.Fatalf("%v expected to be %v, error: %v", link, "/orders/42/files/a%20b/c.txt", err)
+		}
+	})
+
+	t.Run("Check URL errors on invalid params", func(t *testing.T) {
+		for _, params := range []Params{{}, {"id": "abc"}} {
+			if _, err := instance.URL("order.show", params, nil); err == nil {
+				t.Fatalf("%v expected to be invalid", params)
+			}
+		}
+
+		if _, err := instance.URL("order.unknown", nil, nil); err == nil {
+			t.Fatal("Expected error for unknown route")
+		}
+	})
+}
+
+func TestRoutingConflicts(t *testing.T) {
+	t.Run("Check static preferred over param over catch-all regardless of order", func(t *testing.T) {
+		routing := newTestRouting(t, []Route{
+			*NewRoute("/users/*rest", nil).SetParseParams(true).AddMethod(GET),
+			*NewRoute("/users/:id", nil).SetParseParams(true).AddMethod(GET),
+			*NewRoute("/users/me", nil).AddMethod(GET),
+		})
+
+		for path, expected := range map[string]string{
+			"/users/me":       "/users/me",
+			"/users/42":       "/users/:id",
+			"/users/42/posts": "/users/*rest",
+		} {
+			route, found := routing.Match(GET, path)
+
+			if !found || route.Route.Path != expected {
+				t.Fatalf("%v expected to match %v, got %v", path, expected, route.Route)
+			}
+		}
+	})
+
+	t.Run("Check ambiguous routes reported", func(t *testing.T) {
+		cases := [][]Route{
+			{
+				*NewRoute("/users/:id", nil).SetParseParams(true).AddMethod(GET),
+				*NewRoute("/users/:name", nil).SetParseParams(true).AddMethod(GET),
+			},
+			{
+				*NewRoute("/index", nil).AddMethod(GET).AddMethod(POST),
+				*NewRoute("/index", nil).AddMethod(POST),
+			},
+			{
+				*NewRoute("/orders/:id", nil).SetParseParams(true).AddMethod(GET).Name("order"),
+				*NewRoute("/orders", nil).AddMethod(GET).Name("order"),
+			},
+			{
+				*NewRoute("/u/:id<int>", nil).SetParseParams(true).AddMethod(GET),
+				*NewRoute("/u/:n<uint>", nil).SetParseParams(true).AddMethod(GET),
+			},
+			{
+				*NewRoute("/u/:id<int>/posts", nil).SetParseParams(true).AddMethod(GET),
+				*NewRoute("/u/:slug<[a-z-]+>/posts", nil).SetParseParams(true).AddMethod(GET),
+			},
+			{
+				*NewRoute("^/u/me$", nil).SetIsRegexp(true).AddMethod(GET),
+				*NewRoute("/u/me", nil).AddMethod(GET),
+			},
+			{
+				*NewRoute("/users/:id", nil).SetParseParams(true).AddMethod(GET),
+				*NewRoute("^/users/(me|self)$", nil).SetIsRegexp(true).AddMethod(GET),
+			},
+			{
+				*NewRoute("^/files/.+$", nil).SetIsRegexp(true).AddMethod(GET),
+				*NewRoute("^/files/[ab]\\.txt$", nil).SetIsRegexp(true).AddMethod(GET),
+			},
+			{
+				*NewRoute("/index", nil).SetHost("{tenant}.example.com").AddMethod(GET),
+				*NewRoute("/index", nil).SetHost("{name}.Example.com").AddMethod(GET),
+			},
+		}
+
+		for _, routes := range cases {
+			if _, err := NewRouting(routes); err == nil {
+				t.Fatalf("%v %v expected to conflict", routes[0].Path, routes[1].Path)
+			}
+		}
+	})
+
+	t.Run("Check not conflicting routes", func(t *testing.T) {
+		newTestRouting(t, []Route{
+			*NewRoute("/users/:id", nil).SetParseParams(true).AddMethod(GET),
+			*NewRoute("/users/:name", nil).SetParseParams(true).AddMethod(POST),
+			*NewRoute("/users/:id<int>", nil).SetParseParams(true).AddMethod(GET),
+			*NewRoute("/users/:id", nil).SetParseParams(true).SetHost("admin.example.com").AddMethod(GET),
+			*NewRoute("/users/:id<uuid>", nil).SetParseParams(true).AddMethod(GET),
+			*NewRoute("/users/:id<uint>/posts", nil).SetParseParams(true).AddMethod(GET),
+			*NewRoute("/users/:slug<[a-z]+>/comments", nil).SetParseParams(true).AddMethod(GET),
+			*NewRoute("^/users/(me|self)$", nil).SetIsRegexp(true).AddMethod(PUT),
+			*NewRoute("^/users/(me|self)/settings$", nil).SetIsRegexp(true).AddMethod(GET),
+			*NewRoute("^/users/me", nil).SetIsRegexp(true).AddMethod(GET),
+		})
+	})
+}
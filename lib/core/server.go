@@ -1,24 +1,123 @@
 package server
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"exporter-dev/http-server/lib/utils"
+	"fmt"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 	"log"
+	"net"
 	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
 	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 )
 
 const (
-	POST                    = "POST"
-	GET                     = "GET"
-	HEADER_KEY_CONTENT_TYPE = "Content-Type"
+	GET                       = "GET"
+	HEAD                      = "HEAD"
+	POST                      = "POST"
+	PUT                       = "PUT"
+	PATCH                     = "PATCH"
+	DELETE                    = "DELETE"
+	CONNECT                   = "CONNECT"
+	OPTIONS                   = "OPTIONS"
+	TRACE                     = "TRACE"
+	HEADER_KEY_CONTENT_TYPE   = "Content-Type"
+	HEADER_KEY_ALLOW          = "Allow"
+	HEADER_KEY_CONTENT_LENGTH = "Content-Length"
+	HEADER_KEY_LOCATION       = "Location"
 )
 
+// All methods supported by Server
+var METHODS = []string{GET, HEAD, POST, PUT, PATCH, DELETE, CONNECT, OPTIONS, TRACE}
+
+// Methods registered by Server.Any, CONNECT and TRACE should be registered explicitly
+var ANY_METHODS = []string{GET, HEAD, POST, PUT, PATCH, DELETE, OPTIONS}
+
+// Timeouts and limits of http.Server, zero values are replaced with defaults
+// from DefaultServerOptions, negative durations disable timeout
+type ServerOptions struct {
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	MaxHeaderBytes    int
+}
+
+// Get default timeouts and limits, protecting from slow clients
+func DefaultServerOptions() ServerOptions {
+	return ServerOptions{
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      30 * time.Second,
+		IdleTimeout:       120 * time.Second,
+		MaxHeaderBytes:    1 << 20,
+	}
+}
+
+// Get options with zero values replaced with defaults and negative durations with zero
+func (o ServerOptions) normalize() ServerOptions {
+	defaults := DefaultServerOptions()
+
+	duration := func(value time.Duration, fallback time.Duration) time.Duration {
+		if value == 0 {
+			return fallback
+		}
+
+		if value < 0 {
+			return 0
+		}
+
+		return value
+	}
+
+	if o.MaxHeaderBytes <= 0 {
+		o.MaxHeaderBytes = defaults.MaxHeaderBytes
+	}
+
+	return ServerOptions{
+		ReadHeaderTimeout: duration(o.ReadHeaderTimeout, defaults.ReadHeaderTimeout),
+		ReadTimeout:       duration(o.ReadTimeout, defaults.ReadTimeout),
+		WriteTimeout:      duration(o.WriteTimeout, defaults.WriteTimeout),
+		IdleTimeout:       duration(o.IdleTimeout, defaults.IdleTimeout),
+		MaxHeaderBytes:    o.MaxHeaderBytes,
+	}
+}
+
 type Server struct {
-	Port        int
-	Host        string
-	routes      []Route
-	middlewares []Middleware
-	server      *http.Server
-	inited      bool
+	Port             int
+	Host             string
+	TrailingSlash    TrailingSlashPolicy
+	options          ServerOptions
+	routes           []Route
+	middlewares      []Middleware
+	groups           []*Group
+	notFound         RouteHandler
+	hostNotFound     []hostNotFoundHandler
+	methodNotAllowed RouteHandler
+	prepared         http.Handler
+	server           *http.Server
+	listeners        []net.Listener
+	unixSockets      []unixSocket
+	done             chan struct{}
+	serveErr         error
+	shutdownTimeout  time.Duration
+	tlsCertFile      string
+	tlsKeyFile       string
+	tlsConfig        *tls.Config
+	tlsSelfSigned    []string
+	http2Disabled    bool
+	h2c              bool
+	inited           bool
 }
 
 func NewServer() *Server {
@@ -27,6 +126,11 @@ func NewServer() *Server {
 	}
 }
 
+type hostNotFoundHandler struct {
+	host    string
+	handler RouteHandler
+}
+
 type RequestForLog struct {
 	Url        string
 	Method     string
@@ -41,21 +145,54 @@ type ResponseForLog struct {
 }
 
 type Handler struct {
-	Routing      *Routing
-	Middlewaring *Middlewaring
+	Routing       *Routing
+	Middlewaring  *Middlewaring
+	TrailingSlash TrailingSlashPolicy
 }
 
 func (h Handler) ServeHTTP(response http.ResponseWriter, request *http.Request) {
 	var logger = log.New(log.Writer(), "Routing.Execute", log.Flags())
 
-	route := h.Routing.Match(request.Method, request.URL.Path)
+	var controller = NewController(request, response)
+
+	path, escaped := routingPath(request.URL)
+
+	route, found := h.Routing.MatchHost(request.Method, request.Host, path)
+
+	if !found && h.TrailingSlash != TRAILING_SLASH_STRICT {
+		if alternative, ok := h.Routing.MatchHost(request.Method, request.Host, toggleTrailingSlash(path)); ok {
+			route, found, path = alternative, true, toggleTrailingSlash(path)
+		}
+	}
 
-	if route == nil {
+	if found && h.TrailingSlash == TRAILING_SLASH_REDIRECT {
+		if location := canonicalLocation(request.URL, path, escaped); location != "" {
+			logger.Printf("Redirect request (%v) %+v to %+v\n", request.Method, request.URL.Path, location)
+			redirect(response, request, location)
+			return
+		}
+	}
+
+	if escaped {
+		unescapeParams(route.Params)
+	}
+
+	if !found {
 		logger.Printf("Got no handler for request (%v) %+v\n", request.Method, request.URL.Path)
-		return
+
+		if allowed := h.Routing.AllowedHost(request.Host, path); len(allowed) > 0 {
+			controller.Header.Add(HEADER_KEY_ALLOW, strings.Join(allowed, ", "))
+
+			if request.Method == OPTIONS {
+				route.Route = h.optionsRoute(request, path, allowed)
+			} else {
+				route.Route = h.Routing.MethodNotAllowed
+			}
+		} else {
+			route.Route = h.Routing.NotFoundHost(request.Host)
+		}
 	}
 
-	var controller = NewController(request, response)
 	var requestWrapper, err = NewRequest(request, route.Params, route.Route)
 
 	if err != nil {
@@ -64,21 +201,95 @@ func (h Handler) ServeHTTP(response http.ResponseWriter, request *http.Request)
 		return
 	}
 
+	requestWrapper.routing = h.Routing
+	requestWrapper.matchPath = path
+
+	if escaped {
+		path, _ = url.PathUnescape(path)
+	}
+
+	requestWrapper.Path = path
+
 	logger.Printf("Got request: %+v\n", requestWrapper)
 
-	skip, err := h.Middlewaring.Execute(requestWrapper, controller)
+	controller.deferred = true
+
+	// Error of route handler is returned to middlewares and caught after them
+	err = h.Middlewaring.Wrap(requestWrapper, controller, func() error {
+		return h.Routing.execute(&route, requestWrapper, controller)
+	})
+
+	if err != nil && request.Context().Err() != nil {
+		logger.Printf("Request is canceled: %+v", err)
+		return
+	}
 
 	if err != nil {
-		logger.Printf("Got error while handling middlewares: %+v", err)
+		logger.Printf("Got error while handling request: %+v", err)
 		h.Routing.Catch(err, controller, requestWrapper, response)
 		return
 	}
 
-	if !skip {
-		h.Routing.Execute(route, requestWrapper, controller)
+	if err := controller.finish(); err != nil {
+		logger.Printf("Got error while writing response: %+v", err)
+	}
+}
+
+// Get route answering OPTIONS request in group of route matching path for method requested by
+// preflight or for the first allowed method, so middlewares of the group (like CORS) are executed
+func (h Handler) optionsRoute(request *http.Request, path string, allowed []string) *Route {
+	methods := append([]string{request.Header.Get(HEADER_KEY_ACCESS_CONTROL_REQUEST_METHOD)}, allowed...)
+
+	for _, method := range methods {
+		if matched, found := h.Routing.MatchHost(method, request.Host, path); found {
+			options := *h.Routing.Options
+			options.Group = matched.Route.Group
+
+			return &options
+		}
+	}
+
+	return h.Routing.Options
+}
+
+// Get location of canonical path when it differs from requested one, empty otherwise
+func canonicalLocation(requestURL *url.URL, path string, escaped bool) string {
+	requested := requestURL.Path
+
+	if escaped {
+		requested = requestURL.EscapedPath()
+	}
+
+	if path == requested {
+		return ""
 	}
+
+	location := path
+
+	if !escaped {
+		location = (&url.URL{Path: path}).EscapedPath()
+	}
+
+	if requestURL.RawQuery != "" {
+		location += "?" + requestURL.RawQuery
+	}
+
+	return location
 }
 
+// Redirect to location keeping method, 301 for GET and HEAD requests, 308 for others
+func redirect(response http.ResponseWriter, request *http.Request, location string) {
+	status := http.StatusPermanentRedirect
+
+	if request.Method == GET || request.Method == HEAD {
+		status = http.StatusMovedPermanently
+	}
+
+	response.Header().Set(HEADER_KEY_LOCATION, location)
+	response.WriteHeader(status)
+}
+
+// Set port to listen, 0 to listen on random free port (see Server.Addr)
 func (s *Server) SetPort(port int) *Server {
 	if s.inited {
 		panic("Should set Port before Server.Init()")
@@ -89,6 +300,29 @@ func (s *Server) SetPort(port int) *Server {
 	return s
 }
 
+// Set timeouts and limits of http.Server, see ServerOptions
+func (s *Server) SetOptions(options ServerOptions) *Server {
+	if s.inited {
+		panic("Should set Options before Server.Init()")
+	}
+
+	s.options = options
+
+	return s
+}
+
+// Set policy for requests which path differs from route path only by trailing slash
+// or by empty, "." and ".." segments, should be set before Server.Prepare and Server.Init
+func (s *Server) SetTrailingSlash(policy TrailingSlashPolicy) *Server {
+	if s.inited || s.prepared != nil {
+		panic("Should set TrailingSlash before Server.Init()")
+	}
+
+	s.TrailingSlash = policy
+
+	return s
+}
+
 func (s *Server) SetHost(host string) *Server {
 	if s.inited {
 		panic("Should set Host before Server.Init()")
@@ -99,52 +333,421 @@ func (s *Server) SetHost(host string) *Server {
 	return s
 }
 
+// Start listening and serving, blocks until server is stopped. Returns nil when server
+// was stopped by Server.Shutdown, error when it failed to start or serve
 func (s *Server) Init() error {
-	var addr string = ""
+	if err := s.Start(); err != nil {
+		return err
+	}
+
+	return s.Wait()
+}
 
-	if s.Host != "" {
-		addr = s.Host
+// Start listening, returns once listeners are bound and serves requests in background.
+// Use Server.Wait to wait until server is stopped and Server.Shutdown to stop it
+func (s *Server) Start() error {
+	if s.inited {
+		return errors.New("server is already started")
 	}
 
-	if s.Port != 0 {
-		addr = addr + ":" + strconv.Itoa(s.Port)
+	if s.prepared == nil {
+		if err := s.Prepare(); err != nil {
+			log.Printf("Got error while preparing handler: %s", err)
+			return err
+		}
 	}
 
-	log.Printf("Starting server on %+v, with routes (%d) & middlewares (%d)", addr, len(s.routes), len(s.middlewares))
+	tlsConfig, err := s.buildTLSConfig()
 
-	mux := http.NewServeMux()
+	if err != nil {
+		log.Printf("Got error while preparing TLS: %s", err)
+		return err
+	}
 
-	mux.Handle("/", Handler{
-		Routing: &Routing{
-			Routes: s.routes,
-		},
-		Middlewaring: &Middlewaring{
-			Middlewares: s.middlewares,
-		},
-	})
+	listeners, err := s.listen()
+
+	if err != nil {
+		log.Printf("Got error while starting server: %s", err)
+		return err
+	}
+
+	s.inited = true
+	s.listeners = listeners
+	s.done = make(chan struct{})
+
+	log.Printf("Starting server on %+v, with routes (%d) & middlewares (%d)", strings.Join(s.Addrs(), ", "), len(s.routes), len(s.middlewares))
+
+	options := s.options.normalize()
 
 	s.server = &http.Server{
-		Addr:    addr,
-		Handler: mux,
+		Addr:              s.address(),
+		Handler:           s.prepared,
+		ReadHeaderTimeout: options.ReadHeaderTimeout,
+		ReadTimeout:       options.ReadTimeout,
+		WriteTimeout:      options.WriteTimeout,
+		IdleTimeout:       options.IdleTimeout,
+		MaxHeaderBytes:    options.MaxHeaderBytes,
+		TLSConfig:         tlsConfig,
+	}
+
+	if err := s.configureHTTP2(options); err != nil {
+		log.Printf("Got error while configuring HTTP/2: %s", err)
+		return err
 	}
 
-	error := s.server.ListenAndServe()
+	var serving sync.WaitGroup
+	var once sync.Once
 
-	if error != nil {
-		log.Fatalf("Got error while starting server: %s", error)
-		return error
+	for _, listener := range listeners {
+		serving.Add(1)
+
+		go func(listener net.Listener) {
+			defer serving.Done()
+
+			var err error
+
+			if tlsConfig != nil {
+				err = s.server.ServeTLS(listener, "", "")
+			} else {
+				err = s.server.Serve(listener)
+			}
+
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Printf("Got error while serving on %s: %s", listener.Addr(), err)
+
+				// Serving stops on every listener when any of them fails
+				once.Do(func() {
+					s.serveErr = err
+					s.server.Close()
+				})
+			}
+		}(listener)
+	}
+
+	go func() {
+		serving.Wait()
+		close(s.done)
+	}()
+
+	if s.shutdownTimeout > 0 {
+		go s.shutdownOnSignal()
 	}
+
 	return nil
 }
 
-func (s *Server) Get(routes ...Route) {
+// Get TCP address composed from Host and Port
+func (s *Server) address() string {
+	return s.Host + ":" + strconv.Itoa(s.Port)
+}
+
+// Enable or disable HTTP/2 over TLS negotiated by ALPN, enabled by default
+func (s *Server) SetHTTP2(enabled bool) *Server {
+	if s.inited {
+		panic("Should set HTTP2 before Server.Init()")
+	}
+
+	s.http2Disabled = !enabled
+
+	return s
+}
+
+// Enable or disable HTTP/2 over cleartext TCP (h2c) with prior knowledge or by HTTP/1.1
+// "Upgrade: h2c" request, disabled by default
+func (s *Server) SetH2C(enabled bool) *Server {
+	if s.inited {
+		panic("Should set H2C before Server.Init()")
+	}
+
+	s.h2c = enabled
+
+	return s
+}
+
+// Configure HTTP/2 over TLS and h2c, h2c handler serves connections with prior knowledge
+// and upgrades HTTP/1.1 ones. Connections of both are closed gracefully on Server.Shutdown
+func (s *Server) configureHTTP2(options ServerOptions) error {
+	// Not nil empty map disables HTTP/2 over TLS
+	disabled := map[string]func(*http.Server, *tls.Conn, http.Handler){}
+
+	if s.http2Disabled && !s.h2c {
+		s.server.TLSNextProto = disabled
+
+		return nil
+	}
+
+	http2Server := &http2.Server{IdleTimeout: options.IdleTimeout}
+
+	if err := http2.ConfigureServer(s.server, http2Server); err != nil {
+		return err
+	}
+
+	if s.http2Disabled {
+		s.server.TLSNextProto = disabled
+		s.server.TLSConfig.NextProtos = utils.Filter(s.server.TLSConfig.NextProtos, func(protocol string, index int) bool {
+			return protocol != http2.NextProtoTLS
+		})
+	}
+
+	if s.h2c {
+		s.server.Handler = h2c.NewHandler(s.server.Handler, http2Server)
+	}
+
+	return nil
+}
+
+// Wait until started server is stopped, returns error of serving or nil when it was stopped by Server.Shutdown
+func (s *Server) Wait() error {
+	if s.done == nil {
+		return errors.New("server is not started")
+	}
+
+	<-s.done
+
+	return s.serveErr
+}
+
+// Get address of the first listener server listens on, empty when server is not started
+func (s *Server) Addr() string {
+	if !s.inited || len(s.listeners) == 0 {
+		return ""
+	}
+
+	return s.listeners[0].Addr().String()
+}
+
+// Get addresses of all listeners server listens on, empty when server is not started
+func (s *Server) Addrs() []string {
+	if !s.inited {
+		return []string{}
+	}
+
+	addrs := make([]string, len(s.listeners))
+
+	for index, listener := range s.listeners {
+		addrs[index] = listener.Addr().String()
+	}
+
+	return addrs
+}
+
+// Gracefully stop server, stops accepting new connections and waits until in-flight
+// requests are handled, when ctx is done before remaining connections are closed
+func (s *Server) Shutdown(ctx context.Context) error {
+	if s.server == nil {
+		return errors.New("server is not started")
+	}
+
+	log.Printf("Shutting down server on %+v", strings.Join(s.Addrs(), ", "))
+
+	if err := s.server.Shutdown(ctx); err != nil {
+		s.server.Close()
+		<-s.done
+
+		return err
+	}
+
+	<-s.done
+
+	return nil
+}
+
+// Shutdown server on SIGINT or SIGTERM, waiting in-flight requests not longer than timeout
+func (s *Server) SetShutdownOnSignal(timeout time.Duration) *Server {
+	if s.inited {
+		panic("Should set ShutdownOnSignal before Server.Init()")
+	}
+
+	s.shutdownTimeout = timeout
+
+	return s
+}
+
+func (s *Server) shutdownOnSignal() {
+	signals := make(chan os.Signal, 1)
+
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+
+	defer signal.Stop(signals)
+
+	select {
+	case received := <-signals:
+		log.Printf("Got signal %s", received)
+
+		ctx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+		defer cancel()
+
+		if err := s.Shutdown(ctx); err != nil {
+			log.Printf("Got error while shutting down server: %s", err)
+		}
+	case <-s.done:
+	}
+}
+
+// Compile routes and middlewares into handler used by Server.Handler and Server.Init,
+// returns error when any route or middleware is not valid. Routes and middlewares
+// registered after prepare are ignored
+func (s *Server) Prepare() error {
+	handler, err := s.handler()
+
+	if err != nil {
+		return err
+	}
+
+	s.prepared = handler
+
+	return nil
+}
+
+// Get handler serving routes of Server without listening, to use with httptest.Server, another mux, etc.
+// Prepares Server on first call and panics when any route or middleware is not valid,
+// call Server.Prepare before to get error instead
+func (s *Server) Handler() http.Handler {
+	if s.prepared == nil {
+		if err := s.Prepare(); err != nil {
+			panic(err)
+		}
+	}
+
+	return s.prepared
+}
+
+// Build Handler with compiled routes and middlewares
+func (s *Server) handler() (Handler, error) {
+	routing, err := NewRouting(s.routes)
+
+	if err != nil {
+		return Handler{}, err
+	}
+
+	if s.notFound != nil {
+		routing.NotFound.Handler = s.notFound
+	}
+
+	for _, hostNotFound := range s.hostNotFound {
+		if err := routing.SetHostNotFound(hostNotFound.host, hostNotFound.handler); err != nil {
+			return Handler{}, err
+		}
+	}
+
+	if s.methodNotAllowed != nil {
+		routing.MethodNotAllowed.Handler = s.methodNotAllowed
+	}
+
+	middlewaring, err := NewMiddlewaring(s.middlewares)
+
+	if err != nil {
+		return Handler{}, err
+	}
+
+	// Middlewares of groups are copied, so the ones registered after prepare are ignored
+	middlewaring.groups = make(map[*Group][]Middleware, len(s.groups))
+
+	for _, group := range s.groups {
+		if middlewaring.groups[group], err = compileMiddlewares(group.middlewares); err != nil {
+			return Handler{}, err
+		}
+	}
+
+	return Handler{
+		Routing:       routing,
+		Middlewaring:  middlewaring,
+		TrailingSlash: s.TrailingSlash,
+	}, nil
+}
+
+// Build URL of route registered with name, see Route.URL
+func (s *Server) URL(name string, params Params, query url.Values) (string, error) {
+	for index := range s.routes {
+		if s.routes[index].name == name {
+			return s.routes[index].URL(params, query)
+		}
+	}
+
+	return "", fmt.Errorf("route %q not found", name)
+}
+
+// Create group of routes with path prefix and own middlewares
+func (s *Server) Group(path string) *Group {
+	return s.newGroup(path, nil)
+}
+
+func (s *Server) newGroup(path string, parent *Group) *Group {
+	group := &Group{
+		Path:   path,
+		parent: parent,
+		server: s,
+	}
+
+	s.groups = append(s.groups, group)
+
+	return group
+}
+
+// Set handler for requests without matched route, executed after middlewares as normal route
+func (s *Server) NotFound(handler RouteHandler) {
+	s.notFound = handler
+}
+
+// Set handler for requests to host without matched route, host is pattern as for Route.SetHost
+func (s *Server) HostNotFound(host string, handler RouteHandler) {
+	s.hostNotFound = append(s.hostNotFound, hostNotFoundHandler{host, handler})
+}
+
+// Set handler for requests which path matches routes only for other methods,
+// Allow header is already added to response when handler is executed
+func (s *Server) MethodNotAllowed(handler RouteHandler) {
+	s.methodNotAllowed = handler
+}
+
+// Register routes for method
+func (s *Server) Handle(method string, routes ...Route) {
+	for index := range routes {
+		routes[index].AddMethod(method)
+	}
+
+	s.routes = append(s.routes, routes...)
+}
+
+// Register routes for every method from ANY_METHODS
+func (s *Server) Any(routes ...Route) {
 	for index := range routes {
-		routes[index].Method = "GET"
+		for _, method := range ANY_METHODS {
+			routes[index].AddMethod(method)
+		}
 	}
 
 	s.routes = append(s.routes, routes...)
 }
 
+func (s *Server) Get(routes ...Route) {
+	s.Handle(GET, routes...)
+}
+
+func (s *Server) Post(routes ...Route) {
+	s.Handle(POST, routes...)
+}
+
+func (s *Server) Put(routes ...Route) {
+	s.Handle(PUT, routes...)
+}
+
+func (s *Server) Patch(routes ...Route) {
+	s.Handle(PATCH, routes...)
+}
+
+func (s *Server) Delete(routes ...Route) {
+	s.Handle(DELETE, routes...)
+}
+
+func (s *Server) Head(routes ...Route) {
+	s.Handle(HEAD, routes...)
+}
+
+func (s *Server) Options(routes ...Route) {
+	s.Handle(OPTIONS, routes...)
+}
+
 func (s *Server) Use(middlewares ...Middleware) {
 	s.middlewares = append(s.middlewares, middlewares...)
 }
@@ -0,0 +1,909 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func serveTestRequest(t *testing.T, instance *Server, method string, target string) *httptest.ResponseRecorder {
+	if err := instance.Prepare(); err != nil {
+		t.Fatalf("Got error while preparing handler: %v", err)
+	}
+
+	recorder := httptest.NewRecorder()
+
+	instance.Handler().ServeHTTP(recorder, httptest.NewRequest(method, target, nil))
+
+	return recorder
+}
+
+func TestServerNotFound(t *testing.T) {
+	newTestServer := func() *Server {
+		instance := NewServer()
+
+		instance.Get(*NewRoute("/index", func(request *Request, controller *Controller) error {
+			return controller.Send("index")
+		}))
+
+		instance.Post(*NewRoute("/index", func(request *Request, controller *Controller) error {
+			return controller.Send("index")
+		}))
+
+		return instance
+	}
+
+	t.Run("Should respond 404 for unknown path", func(t *testing.T) {
+		recorder := serveTestRequest(t, newTestServer(), GET, "/unknown")
+
+		if recorder.Code != http.StatusNotFound {
+			t.Fatalf("%v expected to be %v", recorder.Code, http.StatusNotFound)
+		}
+	})
+
+	t.Run("Should respond 405 with Allow header for other method", func(t *testing.T) {
+		recorder := serveTestRequest(t, newTestServer(), DELETE, "/index")
+
+		if recorder.Code != http.StatusMethodNotAllowed {
+			t.Fatalf("%v expected to be %v", recorder.Code, http.StatusMethodNotAllowed)
+		}
+
+		if allow := recorder.Header().Get(HEADER_KEY_ALLOW); allow != "GET, HEAD, POST, OPTIONS" {
+			t.Fatalf("%v expected to be %v", allow, "GET, HEAD, POST, OPTIONS")
+		}
+	})
+
+	t.Run("Should execute custom NotFound handler after middlewares", func(t *testing.T) {
+		instance := newTestServer()
+
+		instance.Use(*NewMiddleware(func(request *Request, controller *Controller) (skip bool, err error) {
+			controller.Header.Add("Middleware", "true")
+			return false, nil
+		}))
+
+		instance.NotFound(func(request *Request, controller *Controller) error {
+			controller.Status(http.StatusTeapot)
+			return controller.Send("custom")
+		})
+
+		recorder := serveTestRequest(t, instance, GET, "/unknown")
+
+		if recorder.Code != http.StatusTeapot || recorder.Body.String() != "custom" {
+			t.Fatalf("%v %v expected to be %v custom", recorder.Code, recorder.Body.String(), http.StatusTeapot)
+		}
+
+		if recorder.Header().Get("Middleware") != "true" {
+			t.Fatal("Middleware expected to be executed")
+		}
+	})
+}
+
+func TestServerHeadOptions(t *testing.T) {
+	newTestServer := func() *Server {
+		instance := NewServer()
+
+		instance.Get(*NewRoute("/index", func(request *Request, controller *Controller) error {
+			return controller.Send("index")
+		}))
+
+		instance.Put(*NewRoute("/index", func(request *Request, controller *Controller) error {
+			return controller.Send("index")
+		}))
+
+		return instance
+	}
+
+	t.Run("Should serve HEAD by GET route without body", func(t *testing.T) {
+		recorder := serveTestRequest(t, newTestServer(), HEAD, "/index")
+
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("%v expected to be %v", recorder.Code, http.StatusOK)
+		}
+
+		if recorder.Body.Len() != 0 {
+			t.Fatalf("%v expected to be empty", recorder.Body.String())
+		}
+
+		if length := recorder.Header().Get(HEADER_KEY_CONTENT_LENGTH); length != "5" {
+			t.Fatalf("%v expected to be %v", length, "5")
+		}
+	})
+
+	t.Run("Should answer OPTIONS with Allow header", func(t *testing.T) {
+		recorder := serveTestRequest(t, newTestServer(), OPTIONS, "/index")
+
+		if recorder.Code != http.StatusNoContent {
+			t.Fatalf("%v expected to be %v", recorder.Code, http.StatusNoContent)
+		}
+
+		if allow := recorder.Header().Get(HEADER_KEY_ALLOW); allow != "GET, HEAD, PUT, OPTIONS" {
+			t.Fatalf("%v expected to be %v", allow, "GET, HEAD, PUT, OPTIONS")
+		}
+	})
+
+	t.Run("Should execute explicit OPTIONS route", func(t *testing.T) {
+		instance := newTestServer()
+
+		instance.Options(*NewRoute("/index", func(request *Request, controller *Controller) error {
+			controller.Status(http.StatusOK)
+			return controller.Send("options")
+		}))
+
+		recorder := serveTestRequest(t, instance, OPTIONS, "/index")
+
+		if recorder.Code != http.StatusOK || recorder.Body.String() != "options" {
+			t.Fatalf("%v %v expected to be %v options", recorder.Code, recorder.Body.String(), http.StatusOK)
+		}
+	})
+}
+
+func TestServerGroup(t *testing.T) {
+	newMarkMiddleware := func(mark string) Middleware {
+		return *NewMiddleware(func(request *Request, controller *Controller) (skip bool, err error) {
+			controller.Header.Add("Middlewares", mark)
+			return false, nil
+		})
+	}
+
+	handler := func(request *Request, controller *Controller) error {
+		return controller.Send(request.Route.GroupPath())
+	}
+
+	instance := NewServer()
+
+	instance.Use(newMarkMiddleware("global"))
+
+	api := instance.Group("/api").Use(newMarkMiddleware("api"))
+
+	api.Group("/v1").Use(newMarkMiddleware("v1")).Get(*NewRoute("/users", handler))
+	api.Get(*NewRoute("/health", handler))
+
+	instance.Get(*NewRoute("/index", handler))
+
+	cases := []struct {
+		target      string
+		body        string
+		middlewares []string
+	}{
+		{"/api/v1/users", "/api/v1", []string{"global", "api", "v1"}},
+		{"/api/health", "/api", []string{"global", "api"}},
+		{"/index", "", []string{"global"}},
+	}
+
+	for _, c := range cases {
+		t.Run("Should execute group middlewares for "+c.target, func(t *testing.T) {
+			recorder := serveTestRequest(t, instance, GET, c.target)
+
+			if recorder.Body.String() != c.body {
+				t.Fatalf("%v expected to be %v", recorder.Body.String(), c.body)
+			}
+
+			if middlewares := recorder.Header().Values("Middlewares"); !reflect.DeepEqual(middlewares, c.middlewares) {
+				t.Fatalf("%v expected to be equal %v", middlewares, c.middlewares)
+			}
+		})
+	}
+
+	t.Run("Should ignore group middlewares registered after prepare", func(t *testing.T) {
+		instance.Use(newMarkMiddleware("late-global"))
+		api.Use(newMarkMiddleware("late-api"))
+
+		recorder := httptest.NewRecorder()
+
+		instance.Handler().ServeHTTP(recorder, httptest.NewRequest(GET, "/api/health", nil))
+
+		if middlewares := recorder.Header().Values("Middlewares"); !reflect.DeepEqual(middlewares, []string{"global", "api"}) {
+			t.Fatalf("%v expected to be equal %v", middlewares, []string{"global", "api"})
+		}
+	})
+}
+
+func TestServerHost(t *testing.T) {
+	handler := func(request *Request, controller *Controller) error {
+		return controller.Send(request.Params["tenant"] + ":" + request.Params["id"])
+	}
+
+	instance := NewServer()
+
+	instance.Group("/users").SetHost("{tenant}.example.com").Get(
+		*NewRoute("/:id", handler).SetParseParams(true),
+	)
+
+	instance.Get(*NewRoute("/users/:id", func(request *Request, controller *Controller) error {
+		return controller.Send("exact:" + request.Params["id"])
+	}).SetParseParams(true).SetHost("admin.example.com"))
+	instance.Get(*NewRoute("/users/:id", func(request *Request, controller *Controller) error {
+		return controller.Send("default")
+	}).SetParseParams(true))
+
+	instance.HostNotFound("{tenant}.example.com", func(request *Request, controller *Controller) error {
+		controller.Status(http.StatusNotFound)
+		return controller.Send("tenant not found")
+	})
+
+	cases := []struct {
+		target string
+		status int
+		body   string
+	}{
+		{"http://acme.example.com:3000/users/1", http.StatusOK, "acme:1"},
+		{"http://admin.example.com/users/2", http.StatusOK, "exact:2"},
+		{"http://other.com/users/3", http.StatusOK, "default"},
+		{"http://acme.example.com/unknown", http.StatusNotFound, "tenant not found"},
+		{"http://other.com/unknown", http.StatusNotFound, http.StatusText(http.StatusNotFound)},
+	}
+
+	for _, c := range cases {
+		t.Run("Should route by host "+c.target, func(t *testing.T) {
+			recorder := serveTestRequest(t, instance, GET, c.target)
+
+			if recorder.Code != c.status || recorder.Body.String() != c.body {
+				t.Fatalf("%v %v expected to be %v %v", recorder.Code, recorder.Body.String(), c.status, c.body)
+			}
+		})
+	}
+}
+
+func TestServerTrailingSlash(t *testing.T) {
+	newTestServer := func(policy TrailingSlashPolicy) *Server {
+		instance := NewServer().SetTrailingSlash(policy)
+
+		instance.Get(
+			*NewRoute("/index", func(request *Request, controller *Controller) error {
+				return controller.Send("index")
+			}),
+			*NewRoute("/files/:name/", func(request *Request, controller *Controller) error {
+				return controller.Send(request.Params["name"])
+			}).SetParseParams(true),
+		)
+
+		instance.Post(*NewRoute("/index", func(request *Request, controller *Controller) error {
+			return controller.Send("index")
+		}))
+
+		return instance
+	}
+
+	cases := []struct {
+		name     string
+		policy   TrailingSlashPolicy
+		method   string
+		target   string
+		status   int
+		body     string
+		location string
+	}{
+		{"strict exact path", TRAILING_SLASH_STRICT, GET, "/index", http.StatusOK, "index", ""},
+		{"strict trailing slash", TRAILING_SLASH_STRICT, GET, "/index/", http.StatusNotFound, http.StatusText(http.StatusNotFound), ""},
+		{"strict cleaned path", TRAILING_SLASH_STRICT, GET, "//files/./a/../b/", http.StatusOK, "b", ""},
+		{"lenient trailing slash", TRAILING_SLASH_LENIENT, GET, "/index/", http.StatusOK, "index", ""},
+		{"lenient missing slash", TRAILING_SLASH_LENIENT, GET, "/files/a", http.StatusOK, "a", ""},
+		{"redirect trailing slash", TRAILING_SLASH_REDIRECT, GET, "/index/?a=1", http.StatusMovedPermanently, "", "/index?a=1"},
+		{"redirect trailing slash keeps method", TRAILING_SLASH_REDIRECT, POST, "/index/", http.StatusPermanentRedirect, "", "/index"},
+		{"redirect cleaned path", TRAILING_SLASH_REDIRECT, GET, "/files//a", http.StatusMovedPermanently, "", "/files/a/"},
+		{"decoded params", TRAILING_SLASH_STRICT, GET, "/files/a%2Fb%20c/", http.StatusOK, "a/b c", ""},
+	}
+
+	for _, c := range cases {
+		t.Run("Should handle "+c.name, func(t *testing.T) {
+			recorder := serveTestRequest(t, newTestServer(c.policy), c.method, c.target)
+
+			if recorder.Code != c.status || recorder.Body.String() != c.body {
+				t.Fatalf("%v %v expected to be %v %v", recorder.Code, recorder.Body.String(), c.status, c.body)
+			}
+
+			if location := recorder.Header().Get(HEADER_KEY_LOCATION); location != c.location {
+				t.Fatalf("%v expected to be %v", location, c.location)
+			}
+		})
+	}
+
+	t.Run("Should panic when policy is set after handler is prepared", func(t *testing.T) {
+		instance := newTestServer(TRAILING_SLASH_STRICT)
+
+		if err := instance.Prepare(); err != nil {
+			t.Fatalf("Got error while preparing handler: %v", err)
+		}
+
+		defer func() {
+			if recover() == nil {
+				t.Fatal("Expected panic")
+			}
+		}()
+
+		instance.SetTrailingSlash(TRAILING_SLASH_LENIENT)
+	})
+}
+
+func TestServerMount(t *testing.T) {
+	echo := http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		response.Header().Set("Path", request.URL.Path)
+		response.WriteHeader(http.StatusAccepted)
+	})
+
+	sub := NewServer()
+
+	sub.Use(*NewMiddleware(func(request *Request, controller *Controller) (skip bool, err error) {
+		controller.Header.Add("Middlewares", "sub")
+		return false, nil
+	}))
+
+	sub.Use(*NewMiddleware(func(request *Request, controller *Controller) (skip bool, err error) {
+		controller.Header.Add("Middlewares", "sub-users")
+		return false, nil
+	}).AddPath("/users/:id").SetParseParams(true).ExcludePath("/users/0"))
+
+	sub.Group("/users").Get(*NewRoute("/:id", func(request *Request, controller *Controller) error {
+		return controller.Send(request.Params["id"])
+	}).SetParseParams(true).Name("user"))
+
+	instance := NewServer()
+
+	instance.Use(*NewMiddleware(func(request *Request, controller *Controller) (skip bool, err error) {
+		controller.Header.Add("Middlewares", "global")
+		return false, nil
+	}))
+
+	instance.Mount("/static/", echo)
+	instance.MountServer("/api", sub)
+
+	t.Run("Should strip prefix for mounted handler", func(t *testing.T) {
+		for target, expected := range map[string]string{
+			"/static/css/a.css": "/css/a.css",
+			"/static":           "",
+		} {
+			recorder := serveTestRequest(t, instance, POST, target)
+
+			if recorder.Code != http.StatusAccepted || recorder.Header().Get("Path") != expected {
+				t.Fatalf("%v %v expected to be %v %v", recorder.Code, recorder.Header().Get("Path"), http.StatusAccepted, expected)
+			}
+
+			if recorder.Header().Get("Middlewares") != "global" {
+				t.Fatal("Middleware expected to be executed")
+			}
+		}
+	})
+
+	t.Run("Should serve mounted server routes with its middlewares", func(t *testing.T) {
+		recorder := serveTestRequest(t, instance, GET, "/api/users/42")
+
+		if recorder.Body.String() != "42" {
+			t.Fatalf("%v expected to be %v", recorder.Body.String(), "42")
+		}
+
+		if middlewares := recorder.Header().Values("Middlewares"); !reflect.DeepEqual(middlewares, []string{"global", "sub", "sub-users"}) {
+			t.Fatalf("%v expected to be equal %v", middlewares, []string{"global", "sub", "sub-users"})
+		}
+
+		recorder = serveTestRequest(t, instance, GET, "/api/users/0")
+
+		if middlewares := recorder.Header().Values("Middlewares"); !reflect.DeepEqual(middlewares, []string{"global", "sub"}) {
+			t.Fatalf("%v expected to be equal %v", middlewares, []string{"global", "sub"})
+		}
+
+		if link, _ := instance.URL("user", Params{"id": "1"}, nil); link != "/api/users/1" {
+			t.Fatalf("%v expected to be %v", link, "/api/users/1")
+		}
+	})
+
+	t.Run("Should join mount prefix ending with slash", func(t *testing.T) {
+		for prefix, target := range map[string]string{"/": "/users/42", "/api/": "/api/users/42"} {
+			mounted := NewServer()
+
+			mounted.MountServer(prefix, sub)
+
+			recorder := serveTestRequest(t, mounted, GET, target)
+
+			if recorder.Body.String() != "42" {
+				t.Fatalf("%v expected to be %v for prefix %v", recorder.Body.String(), "42", prefix)
+			}
+
+			if middlewares := recorder.Header().Values("Middlewares"); !reflect.DeepEqual(middlewares, []string{"sub", "sub-users"}) {
+				t.Fatalf("%v expected to be equal %v for prefix %v", middlewares, []string{"sub", "sub-users"}, prefix)
+			}
+		}
+	})
+}
+
+// Response recorder counting written statuses
+type statusCountingRecorder struct {
+	*httptest.ResponseRecorder
+	statuses int
+}
+
+func (r *statusCountingRecorder) WriteHeader(status int) {
+	r.statuses++
+	r.ResponseRecorder.WriteHeader(status)
+}
+
+func TestServerMountResponse(t *testing.T) {
+	t.Run("Should write response of mounted handler as streamed one", func(t *testing.T) {
+		instance := NewServer()
+
+		instance.Use(*NewWrappingMiddleware(func(request *Request, controller *Controller, next func() error) error {
+			controller.Header.Add("Before", "true")
+
+			if err := next(); err != nil {
+				return err
+			}
+
+			controller.Header.Add("After", "true")
+			controller.Status(http.StatusTeapot)
+
+			return controller.Send(";after")
+		}))
+
+		instance.Mount("/static", http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			response.Header().Set("Handler", "true")
+			response.WriteHeader(http.StatusCreated)
+			io.WriteString(response, "mounted")
+		}))
+
+		if err := instance.Prepare(); err != nil {
+			t.Fatalf("Got error while preparing handler: %v", err)
+		}
+
+		recorder := &statusCountingRecorder{ResponseRecorder: httptest.NewRecorder()}
+
+		instance.Handler().ServeHTTP(recorder, httptest.NewRequest(GET, "/static/a.css", nil))
+
+		if recorder.statuses != 1 || recorder.Code != http.StatusCreated {
+			t.Fatalf("%v statuses %v expected to be 1 status %v", recorder.statuses, recorder.Code, http.StatusCreated)
+		}
+
+		if recorder.Body.String() != "mounted;after" {
+			t.Fatalf("%v expected to be %v", recorder.Body.String(), "mounted;after")
+		}
+
+		if recorder.Header().Get("Before") != "true" || recorder.Header().Get("Handler") != "true" || recorder.Header().Get("After") != "" {
+			t.Fatalf("%v expected to have only headers set before and by handler", recorder.Header())
+		}
+	})
+}
+
+func TestServerHandler(t *testing.T) {
+	t.Run("Should serve routes with httptest.Server", func(t *testing.T) {
+		instance := NewServer()
+
+		instance.Get(*NewRoute("/index", func(request *Request, controller *Controller) error {
+			return controller.Send("index")
+		}))
+
+		testServer := httptest.NewServer(instance.Handler())
+		defer testServer.Close()
+
+		response, err := http.Get(testServer.URL + "/index")
+
+		if err != nil {
+			t.Fatalf("Got error while requesting: %v", err)
+		}
+
+		defer response.Body.Close()
+
+		body, _ := io.ReadAll(response.Body)
+
+		if response.StatusCode != http.StatusOK || string(body) != "index" {
+			t.Fatalf("%v %v expected to be %v index", response.StatusCode, string(body), http.StatusOK)
+		}
+	})
+
+	t.Run("Should return error for invalid routes on Prepare", func(t *testing.T) {
+		instance := NewServer()
+
+		instance.Get(*NewRoute("^/(.+$", nil).SetIsRegexp(true))
+
+		if err := instance.Prepare(); err == nil {
+			t.Fatal("Expected error for invalid route")
+		}
+	})
+
+	t.Run("Should return error for invalid group middleware path on Prepare", func(t *testing.T) {
+		instance := NewServer()
+
+		instance.Group("/api").Use(*NewMiddleware(func(request *Request, controller *Controller) (skip bool, err error) {
+			return false, nil
+		}).AddPath("^/(.+$").SetIsRegexp(true))
+
+		if err := instance.Prepare(); err == nil {
+			t.Fatal("Expected error for invalid middleware path")
+		}
+	})
+}
+
+func TestServerStart(t *testing.T) {
+	t.Run("Should serve in background and drain requests on Shutdown", func(t *testing.T) {
+		started := make(chan struct{})
+
+		instance := NewServer().SetHost("127.0.0.1").SetPort(0)
+
+		instance.Get(*NewRoute("/slow", func(request *Request, controller *Controller) error {
+			close(started)
+			time.Sleep(100 * time.Millisecond)
+			return controller.Send("slow")
+		}))
+
+		if err := instance.Start(); err != nil {
+			t.Fatalf("Got error while starting: %v", err)
+		}
+
+		result := make(chan string)
+
+		go func() {
+			response, err := http.Get("http://" + instance.Addr() + "/slow")
+
+			if err != nil {
+				result <- err.Error()
+				return
+			}
+
+			defer response.Body.Close()
+
+			body, _ := io.ReadAll(response.Body)
+
+			result <- string(body)
+		}()
+
+		<-started
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		if err := instance.Shutdown(ctx); err != nil {
+			t.Fatalf("Got error while shutting down: %v", err)
+		}
+
+		if body := <-result; body != "slow" {
+			t.Fatalf("%v expected to be %v", body, "slow")
+		}
+
+		if err := instance.Wait(); err != nil {
+			t.Fatalf("%v expected to be nil", err)
+		}
+	})
+
+	t.Run("Should return error when address is busy", func(t *testing.T) {
+		first := NewServer().SetHost("127.0.0.1").SetPort(0)
+
+		if err := first.Start(); err != nil {
+			t.Fatalf("Got error while starting: %v", err)
+		}
+
+		defer first.Shutdown(context.Background())
+
+		_, port, _ := net.SplitHostPort(first.Addr())
+
+		second := NewServer().SetHost("127.0.0.1")
+		second.Port, _ = strconv.Atoi(port)
+
+		if err := second.Init(); err == nil {
+			t.Fatal("Expected error for busy address")
+		}
+	})
+}
+
+func TestServerOptions(t *testing.T) {
+	t.Run("Should apply defaults and options to http.Server", func(t *testing.T) {
+		instance := NewServer().SetHost("127.0.0.1").SetPort(0).SetOptions(ServerOptions{
+			WriteTimeout: -1,
+			IdleTimeout:  time.Minute,
+		})
+
+		if err := instance.Start(); err != nil {
+			t.Fatalf("Got error while starting: %v", err)
+		}
+
+		defer instance.Shutdown(context.Background())
+
+		defaults := DefaultServerOptions()
+
+		if instance.server.ReadHeaderTimeout != defaults.ReadHeaderTimeout ||
+			instance.server.ReadTimeout != defaults.ReadTimeout ||
+			instance.server.MaxHeaderBytes != defaults.MaxHeaderBytes {
+			t.Fatalf("%+v expected to have defaults %+v", instance.server, defaults)
+		}
+
+		if instance.server.WriteTimeout != 0 || instance.server.IdleTimeout != time.Minute {
+			t.Fatalf("%v %v expected to be %v %v", instance.server.WriteTimeout, instance.server.IdleTimeout, 0, time.Minute)
+		}
+	})
+}
+
+func TestServerHTTP2(t *testing.T) {
+	newStreamingServer := func(received <-chan struct{}) *Server {
+		instance := NewServer().SetHost("127.0.0.1").SetPort(0)
+
+		instance.Get(*NewRoute("/stream", func(request *Request, controller *Controller) error {
+			if err := controller.Stream(request.Original.Proto + ";"); err != nil {
+				return err
+			}
+
+			<-received
+
+			return controller.Send("done")
+		}))
+
+		return instance
+	}
+
+	requestStream := func(t *testing.T, client *http.Client, url string, received chan<- struct{}) {
+		response, err := client.Get(url)
+
+		if err != nil {
+			t.Fatalf("Got error while requesting: %v", err)
+		}
+
+		defer response.Body.Close()
+
+		if response.ProtoMajor != 2 {
+			t.Fatalf("%v expected to be HTTP/2", response.Proto)
+		}
+
+		chunk := make([]byte, len("HTTP/2.0;"))
+
+		if _, err := io.ReadFull(response.Body, chunk); err != nil || string(chunk) != "HTTP/2.0;" {
+			t.Fatalf("%v expected to be streamed before response end, error: %v", string(chunk), err)
+		}
+
+		close(received)
+
+		if rest, _ := io.ReadAll(response.Body); string(rest) != "done" {
+			t.Fatalf("%v expected to be %v", string(rest), "done")
+		}
+	}
+
+	t.Run("Should serve h2c with prior knowledge and stream response", func(t *testing.T) {
+		received := make(chan struct{})
+
+		instance := newStreamingServer(received).SetH2C(true)
+
+		if err := instance.Start(); err != nil {
+			t.Fatalf("Got error while starting: %v", err)
+		}
+
+		defer instance.Shutdown(context.Background())
+
+		client := &http.Client{
+			Transport: &http2.Transport{
+				AllowHTTP: true,
+				DialTLSContext: func(ctx context.Context, network string, address string, config *tls.Config) (net.Conn, error) {
+					return net.Dial(network, address)
+				},
+			},
+		}
+
+		requestStream(t, client, "http://"+instance.Addr()+"/stream", received)
+	})
+
+	t.Run("Should upgrade HTTP/1.1 connection to h2c", func(t *testing.T) {
+		received := make(chan struct{})
+		close(received)
+
+		instance := newStreamingServer(received).SetH2C(true)
+
+		if err := instance.Start(); err != nil {
+			t.Fatalf("Got error while starting: %v", err)
+		}
+
+		defer instance.Shutdown(context.Background())
+
+		connection, err := net.Dial("tcp", instance.Addr())
+
+		if err != nil {
+			t.Fatalf("Got error while dialing: %v", err)
+		}
+
+		defer connection.Close()
+
+		connection.SetDeadline(time.Now().Add(5 * time.Second))
+
+		io.WriteString(connection, "GET /stream HTTP/1.1\r\nHost: "+instance.Addr()+"\r\n"+
+			"Connection: Upgrade, HTTP2-Settings\r\nUpgrade: h2c\r\nHTTP2-Settings: AAMAAABkAAQAAP__\r\n\r\n")
+
+		reader := bufio.NewReader(connection)
+
+		response, err := http.ReadResponse(reader, nil)
+
+		if err != nil || response.StatusCode != http.StatusSwitchingProtocols {
+			t.Fatalf("%v expected to be %v, error: %v", response, http.StatusSwitchingProtocols, err)
+		}
+
+		io.WriteString(connection, http2.ClientPreface)
+
+		framer := http2.NewFramer(connection, reader)
+		framer.WriteSettings()
+
+		var status string
+		var body []byte
+
+		decoder := hpack.NewDecoder(4096, func(field hpack.HeaderField) {
+			if field.Name == ":status" {
+				status = field.Value
+			}
+		})
+
+		for {
+			frame, err := framer.ReadFrame()
+
+			if err != nil {
+				t.Fatalf("Got error while reading frame: %v", err)
+			}
+
+			if frame.Header().StreamID != 1 {
+				continue
+			}
+
+			if headers, ok := frame.(*http2.HeadersFrame); ok {
+				decoder.Write(headers.HeaderBlockFragment())
+			}
+
+			if data, ok := frame.(*http2.DataFrame); ok {
+				body = append(body, data.Data()...)
+			}
+
+			if frame.Header().Flags.Has(http2.FlagDataEndStream) {
+				break
+			}
+		}
+
+		// Upgraded request keeps its HTTP/1.1 protocol, response is sent in HTTP/2 frames
+		if status != "200" || string(body) != "HTTP/1.1;done" {
+			t.Fatalf("%v %v expected to be %v %v", status, string(body), "200", "HTTP/1.1;done")
+		}
+	})
+
+	t.Run("Should negotiate HTTP/2 over TLS and stream response", func(t *testing.T) {
+		received := make(chan struct{})
+
+		instance := newStreamingServer(received).SetTLSSelfSigned()
+
+		if err := instance.Start(); err != nil {
+			t.Fatalf("Got error while starting: %v", err)
+		}
+
+		defer instance.Shutdown(context.Background())
+
+		client := &http.Client{
+			Transport: &http.Transport{
+				ForceAttemptHTTP2: true,
+				TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+			},
+		}
+
+		requestStream(t, client, "https://"+instance.Addr()+"/stream", received)
+	})
+
+	t.Run("Should serve HTTP/1.1 over TLS when HTTP/2 is disabled", func(t *testing.T) {
+		instance := NewServer().SetHost("127.0.0.1").SetPort(0).SetTLSSelfSigned().SetHTTP2(false)
+
+		instance.Get(*NewRoute("/index", func(request *Request, controller *Controller) error {
+			return controller.Send(request.Original.Proto)
+		}))
+
+		if err := instance.Start(); err != nil {
+			t.Fatalf("Got error while starting: %v", err)
+		}
+
+		defer instance.Shutdown(context.Background())
+
+		client := &http.Client{
+			Transport: &http.Transport{
+				ForceAttemptHTTP2: true,
+				TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+			},
+		}
+
+		response, err := client.Get("https://" + instance.Addr() + "/index")
+
+		if err != nil {
+			t.Fatalf("Got error while requesting: %v", err)
+		}
+
+		defer response.Body.Close()
+
+		if body, _ := io.ReadAll(response.Body); response.ProtoMajor != 1 || string(body) != "HTTP/1.1" {
+			t.Fatalf("%v %v expected to be HTTP/1.1", response.Proto, string(body))
+		}
+	})
+}
+
+func TestServerListen(t *testing.T) {
+	t.Run("Should serve the same routes on Unix socket and TCP listener", func(t *testing.T) {
+		socket := filepath.Join(t.TempDir(), "server.sock")
+
+		stale, err := net.Listen("unix", socket)
+
+		if err != nil {
+			t.Fatalf("Got error while creating stale socket: %v", err)
+		}
+
+		stale.(*net.UnixListener).SetUnlinkOnClose(false)
+		stale.Close()
+
+		admin, err := net.Listen("tcp", "127.0.0.1:0")
+
+		if err != nil {
+			t.Fatalf("Got error while listening: %v", err)
+		}
+
+		instance := NewServer().ListenUnix(socket, 0o660).Listen(admin)
+
+		instance.Get(*NewRoute("/", func(request *Request, controller *Controller) error {
+			return controller.Send("index")
+		}))
+
+		if err := instance.Start(); err != nil {
+			t.Fatalf("Got error while starting: %v", err)
+		}
+
+		if addrs := instance.Addrs(); len(addrs) != 2 {
+			t.Fatalf("%v expected to have %v addresses", addrs, 2)
+		}
+
+		if info, err := os.Stat(socket); err != nil || info.Mode().Perm() != 0o660 {
+			t.Fatalf("Socket expected to have mode %v, error: %v", os.FileMode(0o660), err)
+		}
+
+		unixClient := &http.Client{Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network string, addr string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+			},
+		}}
+
+		for _, check := range []struct {
+			client *http.Client
+			url    string
+		}{
+			{unixClient, "http://unix/"},
+			{http.DefaultClient, "http://" + admin.Addr().String() + "/"},
+		} {
+			response, err := check.client.Get(check.url)
+
+			if err != nil {
+				t.Fatalf("Got error while requesting %v: %v", check.url, err)
+			}
+
+			body, _ := io.ReadAll(response.Body)
+			response.Body.Close()
+
+			if string(body) != "index" {
+				t.Fatalf("%v expected to be %v", string(body), "index")
+			}
+		}
+
+		if err := instance.Shutdown(context.Background()); err != nil {
+			t.Fatalf("Got error while shutting down: %v", err)
+		}
+
+		if _, err := os.Stat(socket); !os.IsNotExist(err) {
+			t.Fatalf("Socket expected to be removed, error: %v", err)
+		}
+	})
+
+	t.Run("Should not remove file which is not a socket", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "server.sock")
+
+		if err := os.WriteFile(path, []byte("data"), 0o600); err != nil {
+			t.Fatalf("Got error while writing file: %v", err)
+		}
+
+		if err := NewServer().ListenUnix(path, 0o660).Start(); err == nil {
+			t.Fatal("Expected error for file which is not a socket")
+		}
+
+		if _, err := os.Stat(path); err != nil {
+			t.Fatalf("File expected to be kept, error: %v", err)
+		}
+	})
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Creates new Middleware sending status (503 when 0) when following middlewares and route handler
+// are not finished within timeout. Deadline is attached to Request.Ctx, late handler can't write
+// response after timeout and gets ErrControllerClosed from Controller.Send. Request.Original is
+// restored when handler is finished in time, after timeout it's still used by late handler
+func NewTimeoutMiddleware(timeout time.Duration, status int) *Middleware {
+	if status == 0 {
+		status = http.StatusServiceUnavailable
+	}
+
+	return NewWrappingMiddleware(func(request *Request, controller *Controller, next func() error) error {
+		ctx, cancel := context.WithTimeout(request.Ctx(), timeout)
+		defer cancel()
+
+		original := request.Original
+		request.Original = request.Original.WithContext(ctx)
+
+		done := make(chan error, 1)
+
+		go func() {
+			defer func() {
+				if recovered := recover(); recovered != nil {
+					done <- fmt.Errorf("middleware panic: %v", recovered)
+				}
+			}()
+
+			done <- next()
+		}()
+
+		select {
+		case err := <-done:
+			request.Original = original
+
+			return err
+		case <-ctx.Done():
+		}
+
+		if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			controller.close()
+
+			return ctx.Err()
+		}
+
+		if controller.abort(status, http.StatusText(status)) {
+			log.Printf("Request %s %s is not handled within %s", request.Method, request.Path, timeout)
+		}
+
+		return nil
+	})
+}
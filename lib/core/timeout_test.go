@@ -0,0 +1,132 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestTimeoutMiddleware(t *testing.T) {
+	t.Run("Should send timeout status and close controller for late handler", func(t *testing.T) {
+		late := make(chan error, 1)
+
+		instance := NewServer()
+
+		instance.Use(*NewTimeoutMiddleware(20*time.Millisecond, 0))
+
+		instance.Get(*NewRoute("/slow", func(request *Request, controller *Controller) error {
+			<-request.Ctx().Done()
+			time.Sleep(10 * time.Millisecond)
+
+			err := controller.Send("slow")
+
+			late <- err
+
+			return err
+		}))
+
+		recorder := serveTestRequest(t, instance, GET, "/slow")
+
+		if recorder.Code != http.StatusServiceUnavailable {
+			t.Fatalf("%v expected to be %v", recorder.Code, http.StatusServiceUnavailable)
+		}
+
+		if err := <-late; !errors.Is(err, ErrControllerClosed) {
+			t.Fatalf("%v expected to be %v", err, ErrControllerClosed)
+		}
+
+		if recorder.Body.String() != http.StatusText(http.StatusServiceUnavailable) {
+			t.Fatalf("%v expected to be %v", recorder.Body.String(), http.StatusText(http.StatusServiceUnavailable))
+		}
+	})
+
+	t.Run("Should drop response of late mounted handler", func(t *testing.T) {
+		late := make(chan error, 1)
+
+		instance := NewServer()
+
+		instance.Use(*NewTimeoutMiddleware(20*time.Millisecond, 0))
+
+		instance.Mount("/slow", http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			<-request.Context().Done()
+			time.Sleep(10 * time.Millisecond)
+
+			response.Header().Set("Late", "true")
+
+			_, err := response.Write([]byte("slow"))
+
+			late <- err
+		}))
+
+		recorder := serveTestRequest(t, instance, GET, "/slow")
+
+		if err := <-late; !errors.Is(err, ErrControllerClosed) {
+			t.Fatalf("%v expected to be %v", err, ErrControllerClosed)
+		}
+
+		if recorder.Code != http.StatusServiceUnavailable || recorder.Body.String() != http.StatusText(http.StatusServiceUnavailable) {
+			t.Fatalf("%v %v expected to be %v", recorder.Code, recorder.Body.String(), http.StatusServiceUnavailable)
+		}
+
+		if recorder.Header().Get("Late") != "" {
+			t.Fatal("Header of late handler expected not to be sent")
+		}
+	})
+
+	t.Run("Should apply timeout of route only to its handler", func(t *testing.T) {
+		instance := NewServer()
+
+		instance.Get(
+			*NewRoute("/slow", func(request *Request, controller *Controller) error {
+				if _, ok := request.Ctx().Deadline(); !ok {
+					t.Error("Context expected to have deadline")
+				}
+
+				<-request.Ctx().Done()
+
+				return controller.Send("slow")
+			}).SetTimeout(20*time.Millisecond, http.StatusGatewayTimeout),
+			*NewRoute("/fast", func(request *Request, controller *Controller) error {
+				if _, ok := request.Ctx().Deadline(); ok {
+					t.Error("Context expected not to have deadline")
+				}
+
+				return controller.Send("fast")
+			}),
+		)
+
+		if recorder := serveTestRequest(t, instance, GET, "/slow"); recorder.Code != http.StatusGatewayTimeout {
+			t.Fatalf("%v expected to be %v", recorder.Code, http.StatusGatewayTimeout)
+		}
+
+		if recorder := serveTestRequest(t, instance, GET, "/fast"); recorder.Code != http.StatusOK || recorder.Body.String() != "fast" {
+			t.Fatalf("%v %v expected to be %v %v", recorder.Code, recorder.Body.String(), http.StatusOK, "fast")
+		}
+	})
+
+	t.Run("Should keep response of handler finished within timeout", func(t *testing.T) {
+		instance := NewServer()
+
+		instance.Use(*NewWrappingMiddleware(func(request *Request, controller *Controller, next func() error) error {
+			err := next()
+
+			if _, ok := request.Ctx().Deadline(); ok || request.Ctx().Err() != nil {
+				t.Error("Context of request expected to be restored")
+			}
+
+			return err
+		}))
+
+		instance.Use(*NewTimeoutMiddleware(time.Second, http.StatusGatewayTimeout))
+
+		instance.Get(*NewRoute("/index", func(request *Request, controller *Controller) error {
+			controller.Status(http.StatusCreated)
+			return controller.Send("index")
+		}))
+
+		if recorder := serveTestRequest(t, instance, GET, "/index"); recorder.Code != http.StatusCreated || recorder.Body.String() != "index" {
+			t.Fatalf("%v %v expected to be %v %v", recorder.Code, recorder.Body.String(), http.StatusCreated, "index")
+		}
+	})
+}
@@ -0,0 +1,257 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"log"
+	"math/big"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// How often certificate files are checked for changes
+const CERTIFICATE_RELOAD_INTERVAL = 10 * time.Second
+
+// Certificate loaded from files and reloaded when files are modified on disk
+type certificateReloader struct {
+	certFile    string
+	keyFile     string
+	interval    time.Duration
+	mutex       sync.Mutex
+	certificate *tls.Certificate
+	modified    time.Time
+	checked     time.Time
+}
+
+// Creates new certificateReloader, returns error when certificate can't be loaded
+func newCertificateReloader(certFile string, keyFile string) (*certificateReloader, error) {
+	reloader := &certificateReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		interval: CERTIFICATE_RELOAD_INTERVAL,
+	}
+
+	modified, err := reloader.modifiedAt()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := reloader.load(modified); err != nil {
+		return nil, err
+	}
+
+	return reloader, nil
+}
+
+// Get latest modification time of certificate and key files
+func (r *certificateReloader) modifiedAt() (time.Time, error) {
+	var modified time.Time
+
+	for _, file := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(file)
+
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		if info.ModTime().After(modified) {
+			modified = info.ModTime()
+		}
+	}
+
+	return modified, nil
+}
+
+func (r *certificateReloader) load(modified time.Time) error {
+	certificate, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+
+	if err != nil {
+		return err
+	}
+
+	r.certificate = &certificate
+	r.modified = modified
+	r.checked = time.Now()
+
+	return nil
+}
+
+// Get certificate for tls.Config, reloads it when files were modified since last load.
+// When reload fails previous certificate is kept
+func (r *certificateReloader) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if time.Since(r.checked) < r.interval {
+		return r.certificate, nil
+	}
+
+	r.checked = time.Now()
+
+	modified, err := r.modifiedAt()
+
+	if err != nil {
+		log.Printf("Got error while checking certificate: %s", err)
+		return r.certificate, nil
+	}
+
+	if modified.After(r.modified) {
+		if err := r.load(modified); err != nil {
+			log.Printf("Got error while reloading certificate: %s", err)
+		} else {
+			log.Printf("Reloaded certificate %s", r.certFile)
+		}
+	}
+
+	return r.certificate, nil
+}
+
+// Generate self-signed certificate and key in PEM for hosts, valid for one year
+func generateSelfSigned(hosts []string) (certPEM []byte, keyPEM []byte, err error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"Self-signed"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(1, 0, 0),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+
+	return certPEM, keyPEM, nil
+}
+
+// Set certificate and key files to serve HTTPS, files are reloaded when modified on disk
+func (s *Server) SetTLS(certFile string, keyFile string) *Server {
+	if s.inited {
+		panic("Should set TLS before Server.Init()")
+	}
+
+	s.tlsCertFile = certFile
+	s.tlsKeyFile = keyFile
+
+	return s
+}
+
+// Set tls.Config to serve HTTPS, certificate from Server.SetTLS or Server.SetTLSSelfSigned
+// is used when config has no certificates
+func (s *Server) SetTLSConfig(config *tls.Config) *Server {
+	if s.inited {
+		panic("Should set TLSConfig before Server.Init()")
+	}
+
+	s.tlsConfig = config
+
+	return s
+}
+
+// Serve HTTPS with self-signed certificate generated in memory on start, for local development only.
+// Certificate is issued for hosts, "localhost" and "127.0.0.1" when hosts are not passed
+func (s *Server) SetTLSSelfSigned(hosts ...string) *Server {
+	if s.inited {
+		panic("Should set TLSSelfSigned before Server.Init()")
+	}
+
+	if len(hosts) == 0 {
+		hosts = []string{"localhost", "127.0.0.1"}
+	}
+
+	s.tlsSelfSigned = hosts
+
+	return s
+}
+
+// Build tls.Config by TLS settings of Server, nil when TLS is not configured
+func (s *Server) buildTLSConfig() (*tls.Config, error) {
+	if s.tlsConfig == nil && s.tlsCertFile == "" && s.tlsSelfSigned == nil {
+		return nil, nil
+	}
+
+	config := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if s.tlsConfig != nil {
+		config = s.tlsConfig.Clone()
+	}
+
+	if len(config.Certificates) > 0 || config.GetCertificate != nil {
+		return config, nil
+	}
+
+	switch {
+	case s.tlsCertFile != "":
+		reloader, err := newCertificateReloader(s.tlsCertFile, s.tlsKeyFile)
+
+		if err != nil {
+			return nil, err
+		}
+
+		config.GetCertificate = reloader.GetCertificate
+	case s.tlsSelfSigned != nil:
+		certPEM, keyPEM, err := generateSelfSigned(s.tlsSelfSigned)
+
+		if err != nil {
+			return nil, err
+		}
+
+		certificate, err := tls.X509KeyPair(certPEM, keyPEM)
+
+		if err != nil {
+			return nil, err
+		}
+
+		config.Certificates = []tls.Certificate{certificate}
+	default:
+		if config.GetConfigForClient == nil {
+			return nil, errors.New("TLS config has no certificate")
+		}
+	}
+
+	return config, nil
+}
@@ -0,0 +1,115 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeSelfSigned(t *testing.T, certFile string, keyFile string, modified time.Time) {
+	certPEM, keyPEM, err := generateSelfSigned([]string{"localhost"})
+
+	if err != nil {
+		t.Fatalf("Got error while generating certificate: %v", err)
+	}
+
+	for file, content := range map[string][]byte{certFile: certPEM, keyFile: keyPEM} {
+		if err := os.WriteFile(file, content, 0600); err != nil {
+			t.Fatalf("Got error while writing %v: %v", file, err)
+		}
+
+		if err := os.Chtimes(file, modified, modified); err != nil {
+			t.Fatalf("Got error while touching %v: %v", file, err)
+		}
+	}
+}
+
+func TestCertificateReloader(t *testing.T) {
+	t.Run("Should reload certificate when files are modified", func(t *testing.T) {
+		directory := t.TempDir()
+		certFile := filepath.Join(directory, "cert.pem")
+		keyFile := filepath.Join(directory, "key.pem")
+
+		writeSelfSigned(t, certFile, keyFile, time.Now().Add(-time.Minute))
+
+		reloader, err := newCertificateReloader(certFile, keyFile)
+
+		if err != nil {
+			t.Fatalf("Got error while loading certificate: %v", err)
+		}
+
+		reloader.interval = 0
+
+		first, _ := reloader.GetCertificate(nil)
+
+		if same, _ := reloader.GetCertificate(nil); same != first {
+			t.Fatal("Certificate expected not to be reloaded without changes")
+		}
+
+		writeSelfSigned(t, certFile, keyFile, time.Now())
+
+		if second, _ := reloader.GetCertificate(nil); second == first {
+			t.Fatal("Certificate expected to be reloaded after changes")
+		}
+
+		if err := os.WriteFile(certFile, []byte("broken"), 0600); err != nil {
+			t.Fatalf("Got error while writing %v: %v", certFile, err)
+		}
+
+		os.Chtimes(certFile, time.Now().Add(time.Minute), time.Now().Add(time.Minute))
+
+		if kept, _ := reloader.GetCertificate(nil); kept == nil {
+			t.Fatal("Previous certificate expected to be kept when reload fails")
+		}
+	})
+}
+
+func TestServerTLS(t *testing.T) {
+	t.Run("Should serve HTTPS with self-signed certificate", func(t *testing.T) {
+		instance := NewServer().SetHost("127.0.0.1").SetPort(0).SetTLSSelfSigned()
+
+		instance.Get(*NewRoute("/index", func(request *Request, controller *Controller) error {
+			return controller.Send("secure")
+		}))
+
+		if err := instance.Start(); err != nil {
+			t.Fatalf("Got error while starting: %v", err)
+		}
+
+		defer instance.Shutdown(context.Background())
+
+		client := &http.Client{
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+			},
+		}
+
+		response, err := client.Get("https://" + instance.Addr() + "/index")
+
+		if err != nil {
+			t.Fatalf("Got error while requesting: %v", err)
+		}
+
+		defer response.Body.Close()
+
+		body, _ := io.ReadAll(response.Body)
+
+		if response.TLS == nil || string(body) != "secure" {
+			t.Fatalf("%v expected to be served over TLS", string(body))
+		}
+	})
+
+	t.Run("Should return error on start when certificate files are missing", func(t *testing.T) {
+		instance := NewServer().SetHost("127.0.0.1").SetPort(0).SetTLS("missing.pem", "missing.key")
+
+		if err := instance.Start(); err == nil {
+			instance.Shutdown(context.Background())
+			t.Fatal("Expected error for missing certificate")
+		}
+	})
+}
@@ -0,0 +1,384 @@
+package server
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+type nodeKind uint8
+
+const (
+	nodeStatic nodeKind = iota
+	nodeParam
+	nodeCatchAll
+)
+
+// Node of compressed prefix tree, static nodes hold path prefix,
+// param and catch-all nodes hold name of captured value, params could have static suffix
+// within their segment like :name.json
+type node struct {
+	kind       nodeKind
+	path       string
+	name       string
+	constraint string
+	suffix     string
+	validator  *regexp.Regexp
+	indices    string
+	children   []*node
+	wildcards  []*node
+	routes     map[string]*Route
+}
+
+// Part of route pattern, either static text or :param / *catchAll with optional <constraint>
+type patternToken struct {
+	kind       nodeKind
+	value      string
+	constraint string
+}
+
+// Param names start with letter or underscore, following chars can also be digits
+func isParamNameChar(c byte, first bool) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || !first && c >= '0' && c <= '9'
+}
+
+// Split route pattern into static and wildcard tokens, when parseParams is false the whole pattern is static
+func tokenizePattern(pattern string, parseParams bool) ([]patternToken, error) {
+	if !parseParams {
+		return []patternToken{{kind: nodeStatic, value: pattern}}, nil
+	}
+
+	var tokens []patternToken
+
+	start := 0
+
+	for index := 0; index < len(pattern); index++ {
+		c := pattern[index]
+
+		if c != ':' && c != '*' {
+			continue
+		}
+
+		end := index + 1
+
+		for end < len(pattern) && isParamNameChar(pattern[end], end == index+1) {
+			end++
+		}
+
+		if end == index+1 {
+			continue
+		}
+
+		if index > start {
+			tokens = append(tokens, patternToken{kind: nodeStatic, value: pattern[start:index]})
+		}
+
+		kind := nodeParam
+
+		if c == '*' {
+			kind = nodeCatchAll
+		}
+
+		token := patternToken{kind: kind, value: pattern[index+1 : end]}
+
+		if end < len(pattern) && pattern[end] == '<' {
+			closing, depth := end+1, 1
+
+			for ; closing < len(pattern) && depth > 0; closing++ {
+				switch pattern[closing] {
+				case '<':
+					depth++
+				case '>':
+					depth--
+				}
+			}
+
+			if depth > 0 {
+				return nil, fmt.Errorf("invalid path %q: constraint of %c%s is not closed", pattern, c, token.value)
+			}
+
+			token.constraint = pattern[end+1 : closing-1]
+			end = closing
+		}
+
+		tokens = append(tokens, token)
+
+		start = end
+		index = end - 1
+	}
+
+	if start < len(pattern) || len(tokens) == 0 {
+		tokens = append(tokens, patternToken{kind: nodeStatic, value: pattern[start:]})
+	}
+
+	return tokens, nil
+}
+
+func commonPrefixLength(a, b string) int {
+	length := 0
+
+	for length < len(a) && length < len(b) && a[length] == b[length] {
+		length++
+	}
+
+	return length
+}
+
+// Check wildcards of pattern could be matched, catch-all should be at the end of path and param
+// should be followed by '/', static suffix or end of path, not by another wildcard in its segment
+func validatePattern(pattern string, tokens []patternToken) error {
+	for index, token := range tokens[:len(tokens)-1] {
+		next := tokens[index+1]
+
+		switch {
+		case token.kind == nodeCatchAll:
+			return fmt.Errorf("invalid path %q: catch-all *%s should be at the end of path", pattern, token.value)
+		case token.kind == nodeParam && next.kind != nodeStatic,
+			token.kind == nodeParam && !strings.Contains(next.value, "/") && index+2 < len(tokens):
+			return fmt.Errorf("invalid path %q: param :%s should be followed by '/', static suffix or end of path", pattern, token.value)
+		}
+	}
+
+	return nil
+}
+
+// Split static text on the end of segment, returns suffix of previous segment and the rest
+func splitSegment(value string) (suffix string, rest string) {
+	if end := strings.IndexByte(value, '/'); end >= 0 {
+		return value[:end], value[end:]
+	}
+
+	return value, ""
+}
+
+// Insert route by pattern, params are parsed from pattern only when parseParams is true.
+// Returns error when pattern has wildcard which never could be matched
+func (n *node) insert(pattern string, parseParams bool, route *Route) error {
+	current := n
+
+	tokens, err := tokenizePattern(pattern, parseParams)
+
+	if err != nil {
+		return err
+	}
+
+	if err := validatePattern(pattern, tokens); err != nil {
+		return err
+	}
+
+	for index, token := range tokens {
+		if token.kind == nodeStatic {
+			current = current.insertStatic(token.value)
+			continue
+		}
+
+		var suffix string
+
+		// Static text up to the end of segment is matched by param itself
+		if token.kind == nodeParam && index+1 < len(tokens) {
+			suffix, tokens[index+1].value = splitSegment(tokens[index+1].value)
+		}
+
+		if current, err = current.insertWildcard(token, suffix); err != nil {
+			return fmt.Errorf("invalid path %q: %w", pattern, err)
+		}
+	}
+
+	if current.routes == nil {
+		current.routes = make(map[string]*Route)
+	}
+
+	for _, method := range route.Method {
+		if _, exists := current.routes[method]; !exists {
+			current.routes[method] = route
+		}
+	}
+
+	return nil
+}
+
+// Insert static path below node, splitting existing children on common prefix
+func (n *node) insertStatic(path string) *node {
+	if path == "" {
+		return n
+	}
+
+	for index := 0; index < len(n.indices); index++ {
+		if n.indices[index] != path[0] {
+			continue
+		}
+
+		child := n.children[index]
+		length := commonPrefixLength(path, child.path)
+
+		if length < len(child.path) {
+			split := &node{
+				kind:     nodeStatic,
+				path:     child.path[:length],
+				indices:  string(child.path[length]),
+				children: []*node{child},
+			}
+
+			child.path = child.path[length:]
+			n.children[index] = split
+			child = split
+		}
+
+		return child.insertStatic(path[length:])
+	}
+
+	child := &node{kind: nodeStatic, path: path}
+
+	n.indices += string(path[0])
+	n.children = append(n.children, child)
+
+	return child
+}
+
+// Insert param or catch-all below node, params are kept before catch-alls, params with
+// longer suffix before others and constrained wildcards before unconstrained ones
+func (n *node) insertWildcard(token patternToken, suffix string) (*node, error) {
+	for _, child := range n.wildcards {
+		if child.kind == token.kind && child.name == token.value && child.constraint == token.constraint && child.suffix == suffix {
+			return child, nil
+		}
+	}
+
+	child := &node{kind: token.kind, name: token.value, constraint: token.constraint, suffix: suffix}
+
+	if token.constraint != "" {
+		validator, err := compileConstraint(token.constraint)
+
+		if err != nil {
+			return nil, err
+		}
+
+		child.validator = validator
+	}
+
+	n.wildcards = append(n.wildcards, child)
+
+	sort.SliceStable(n.wildcards, func(i, j int) bool {
+		a, b := n.wildcards[i], n.wildcards[j]
+
+		if a.kind != b.kind {
+			return a.kind < b.kind
+		}
+
+		if len(a.suffix) != len(b.suffix) {
+			return len(a.suffix) > len(b.suffix)
+		}
+
+		return a.validator != nil && b.validator == nil
+	})
+
+	return child, nil
+}
+
+// Consume part of path matched by node, returns captured value for params and catch-alls
+func (n *node) consume(path string) (value string, rest string, ok bool) {
+	switch n.kind {
+	case nodeStatic:
+		if !strings.HasPrefix(path, n.path) {
+			return "", "", false
+		}
+
+		rest = path[len(n.path):]
+	case nodeParam:
+		end := strings.IndexByte(path, '/')
+
+		if end < 0 {
+			end = len(path)
+		}
+
+		segment := path[:end]
+
+		if len(segment) <= len(n.suffix) || !strings.HasSuffix(segment, n.suffix) {
+			return "", "", false
+		}
+
+		value, rest = segment[:len(segment)-len(n.suffix)], path[end:]
+	case nodeCatchAll:
+		value, rest = path, ""
+	}
+
+	if n.validator != nil && !n.validator.MatchString(value) {
+		return "", "", false
+	}
+
+	return value, rest, true
+}
+
+// Find route for method and path, static children are preferred over params and params over catch-alls.
+// Captured values are written to params, which is created only when route has any
+func (n *node) find(method string, path string, params *Params) *Route {
+	value, path, ok := n.consume(path)
+
+	if !ok {
+		return nil
+	}
+
+	route := n.findChild(method, path, params)
+
+	if route != nil && n.kind != nodeStatic {
+		if *params == nil {
+			*params = Params{}
+		}
+
+		(*params)[n.name] = value
+	}
+
+	return route
+}
+
+func (n *node) findChild(method string, path string, params *Params) *Route {
+	if path == "" {
+		if route := n.routes[method]; route != nil {
+			return route
+		}
+	} else {
+		for index := 0; index < len(n.indices); index++ {
+			if n.indices[index] == path[0] {
+				if route := n.children[index].find(method, path, params); route != nil {
+					return route
+				}
+
+				break
+			}
+		}
+	}
+
+	for _, child := range n.wildcards {
+		if route := child.find(method, path, params); route != nil {
+			return route
+		}
+	}
+
+	return nil
+}
+
+// Collect methods of every route matching path
+func (n *node) allowed(path string, methods map[string]bool) {
+	_, path, ok := n.consume(path)
+
+	if !ok {
+		return
+	}
+
+	if path == "" {
+		for method := range n.routes {
+			methods[method] = true
+		}
+	} else {
+		for index := 0; index < len(n.indices); index++ {
+			if n.indices[index] == path[0] {
+				n.children[index].allowed(path, methods)
+			}
+		}
+	}
+
+	for _, child := range n.wildcards {
+		child.allowed(path, methods)
+	}
+}
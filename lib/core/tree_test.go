@@ -0,0 +1,205 @@
+package server
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestTreeFind(t *testing.T) {
+	newTree := func(routes ...*Route) *node {
+		tree := &node{kind: nodeStatic}
+
+		for _, route := range routes {
+			tree.insert(route.Path, route.ParseParams, route.AddMethod(GET))
+		}
+
+		return tree
+	}
+
+	t.Run("Check static routes with common prefix", func(t *testing.T) {
+		index := NewRoute("/index", nil)
+		info := NewRoute("/info", nil)
+		indexes := NewRoute("/indexes", nil)
+
+		tree := newTree(index, info, indexes)
+
+		for path, expected := range map[string]*Route{
+			"/index":   index,
+			"/info":    info,
+			"/indexes": indexes,
+			"/inde":    nil,
+			"/":        nil,
+		} {
+			var params Params
+
+			if route := tree.find(GET, path, &params); route != expected {
+				t.Fatalf("%v expected to find %v, got %v", path, expected, route)
+			}
+
+			if params != nil {
+				t.Fatalf("%v expected to be nil", params)
+			}
+		}
+	})
+
+	t.Run("Check params and catch-all routes", func(t *testing.T) {
+		user := NewRoute("/users/:id", nil).SetParseParams(true)
+		me := NewRoute("/users/me", nil)
+		files := NewRoute("/files/*path", nil).SetParseParams(true)
+
+		tree := newTree(user, me, files)
+
+		cases := []struct {
+			path   string
+			route  *Route
+			params Params
+		}{
+			{"/users/me", me, nil},
+			{"/users/42", user, Params{"id": "42"}},
+			{"/users/42/delete", nil, nil},
+			{"/users/", nil, nil},
+			{"/files/a/b.txt", files, Params{"path": "a/b.txt"}},
+		}
+
+		for _, c := range cases {
+			var params Params
+
+			route := tree.find(GET, c.path, &params)
+
+			if route != c.route {
+				t.Fatalf("%v expected to find %v, got %v", c.path, c.route, route)
+			}
+
+			if !reflect.DeepEqual(params, c.params) {
+				t.Fatalf("%v expected to be equal %v", params, c.params)
+			}
+		}
+	})
+
+	t.Run("Check constrained params fall through", func(t *testing.T) {
+		byID := NewRoute("/orders/:id<int>", nil).SetParseParams(true)
+		bySlug := NewRoute("/orders/:slug<[a-z-]+>", nil).SetParseParams(true)
+		byUUID := NewRoute("/orders/:uuid<uuid>/items", nil).SetParseParams(true)
+
+		tree := newTree(bySlug, byID, byUUID)
+
+		cases := []struct {
+			path   string
+			route  *Route
+			params Params
+		}{
+			{"/orders/42", byID, Params{"id": "42"}},
+			{"/orders/new-order", bySlug, Params{"slug": "new-order"}},
+			{"/orders/3f2504e0-4f89-11d3-9a0c-0305e82c3301/items", byUUID, Params{"uuid": "3f2504e0-4f89-11d3-9a0c-0305e82c3301"}},
+			{"/orders/New", nil, nil},
+		}
+
+		for _, c := range cases {
+			var params Params
+
+			route := tree.find(GET, c.path, &params)
+
+			if route != c.route {
+				t.Fatalf("%v expected to find %v, got %v", c.path, c.route, route)
+			}
+
+			if !reflect.DeepEqual(params, c.params) {
+				t.Fatalf("%v expected to be equal %v", params, c.params)
+			}
+		}
+	})
+
+	t.Run("Check params with static suffix", func(t *testing.T) {
+		json := NewRoute("/files/:name.json", nil).SetParseParams(true)
+		archive := NewRoute("/files/:name.tar.gz/info", nil).SetParseParams(true)
+		file := NewRoute("/files/:name", nil).SetParseParams(true)
+
+		tree := newTree(file, json, archive)
+
+		cases := []struct {
+			path   string
+			route  *Route
+			params Params
+		}{
+			{"/files/report.json", json, Params{"name": "report"}},
+			{"/files/report.tar.gz/info", archive, Params{"name": "report"}},
+			{"/files/report.tar.gz", file, Params{"name": "report.tar.gz"}},
+			{"/files/.json", file, Params{"name": ".json"}},
+		}
+
+		for _, c := range cases {
+			var params Params
+
+			if route := tree.find(GET, c.path, &params); route != c.route {
+				t.Fatalf("%v expected to find %v, got %v", c.path, c.route, route)
+			}
+
+			if !reflect.DeepEqual(params, c.params) {
+				t.Fatalf("%v expected to be equal %v", params, c.params)
+			}
+		}
+	})
+
+	t.Run("Check fallback to param when static has no method", func(t *testing.T) {
+		user := NewRoute("/users/:id", nil).SetParseParams(true).AddMethod(POST)
+		me := NewRoute("/users/me", nil)
+
+		tree := newTree(me)
+		tree.insert(user.Path, user.ParseParams, user)
+
+		var params Params
+
+		if route := tree.find(POST, "/users/me", &params); route != user {
+			t.Fatalf("/users/me expected to find %v, got %v", user, route)
+		}
+	})
+}
+
+func newBenchmarkRouting() *Routing {
+	var routes []Route
+
+	for index := 0; index < 300; index++ {
+		id := strconv.Itoa(index)
+
+		routes = append(
+			routes,
+			*NewRoute("/static/"+id+"/index", nil).AddMethod(GET),
+			*NewRoute("/params/"+id+"/:id/:style", nil).SetParseParams(true).AddMethod(GET),
+		)
+	}
+
+	routing, err := NewRouting(routes)
+
+	if err != nil {
+		panic(err)
+	}
+
+	return routing
+}
+
+func BenchmarkRoutingMatchStatic(b *testing.B) {
+	routing := newBenchmarkRouting()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for index := 0; index < b.N; index++ {
+		if _, found := routing.Match(GET, "/static/299/index"); !found {
+			b.Fatal("/static/299/index expected to match")
+		}
+	}
+}
+
+func BenchmarkRoutingMatchParams(b *testing.B) {
+	routing := newBenchmarkRouting()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for index := 0; index < b.N; index++ {
+		if _, found := routing.Match(GET, "/params/299/123/321"); !found {
+			b.Fatal("/params/299/123/321 expected to match")
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	server "exporter-dev/http-server/lib/core"
 	"fmt"
 	"log"
+	"time"
 )
 
 type Test struct {
@@ -13,7 +14,11 @@ type Test struct {
 
 func main() {
 	// Create new Server instance, and SetPort (by default will use 80 port):
-	var instance = server.NewServer().SetPort(3000)
+	var instance = server.NewServer().SetPort(3000).
+		// Redirect "/index/" to "/index" and "/index/1" to "/index/1/" (by default paths should match exactly):
+		SetTrailingSlash(server.TRAILING_SLASH_REDIRECT).
+		// Gracefully shutdown on SIGINT/SIGTERM, waiting in-flight requests up to 10 seconds:
+		SetShutdownOnSignal(10 * time.Second)
 
 	// Register middlewares in first register - first execute order:
 	instance.Use(
@@ -46,10 +51,18 @@ func main() {
 		).AddPath("/index"),
 	)
 
-	// Create RouteGroup with path where all children routes
-	// will be accessed with "/index" + children route path:
-	indexRouteGroup := server.NewRouteGroup(
-		"/index",
+	// Create Group with path where all children routes
+	// will be accessed with "/index" + children route path,
+	// middlewares registered by Group.Use are executed only for the group routes:
+	instance.Group("/index").Use(
+		*server.NewMiddleware(
+			func(request *server.Request, controller *server.Controller) (skip bool, err error) {
+				log.Printf("Group %s middleware", request.Route.GroupPath())
+
+				return false, nil
+			},
+		),
+	).Get(
 		*server.NewRoute(
 			"",
 			func(request *server.Request, controller *server.Controller) error {
@@ -96,11 +109,8 @@ func main() {
 		).SetParseParams(true),
 	)
 
-	// Register route group
-	instance.Get(
-		indexRouteGroup...,
-	)
-
-	// Init server listening
-	instance.Init()
+	// Init server listening, blocks until server is stopped:
+	if err := instance.Init(); err != nil {
+		log.Fatalf("Got error while serving: %s", err)
+	}
 }